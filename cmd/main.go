@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/nkchakradhari780/catalogServices/internal/api"
+	"github.com/nkchakradhari780/catalogServices/internal/blob"
 	"github.com/nkchakradhari780/catalogServices/internal/cache"
 	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/feed"
+	"github.com/nkchakradhari780/catalogServices/internal/jobs"
 	"github.com/nkchakradhari780/catalogServices/internal/repository/storage/postgres"
+	"github.com/nkchakradhari780/catalogServices/internal/scheduler"
 )
 
 func main() {
@@ -29,42 +35,218 @@ func main() {
 	slog.Info("Connected to Database") 
 	cache.InitRedis()
 
+	blobStore, err := blob.New(cfg.Blob)
+	if err != nil {
+		log.Fatalf("Failed to set up blob storage %s", err)
+	}
+
+	guestCarts, err := api.NewGuestCarts(cfg.Cart)
+	if err != nil {
+		log.Fatalf("Failed to set up guest carts %s", err)
+	}
+	sessions, err := api.NewSessions(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Failed to set up sessions %s", err)
+	}
+	if !api.IsAllocationStrategy(cfg.Inventory.Allocation) {
+		log.Fatalf("Unknown inventory allocation strategy %q", cfg.Inventory.Allocation)
+	}
+
 	//Router Setup
 	router := http.NewServeMux() 
 
 	router.HandleFunc("POST /admin/products", api.CreateNewProduct(storage))
+	router.HandleFunc("POST /admin/products/import", api.ImportProducts(storage, cfg.Jobs))
+	router.HandleFunc("GET /admin/products/export", api.ExportProducts(storage))
+	router.HandleFunc("POST /admin/products/bulk-price", api.BulkUpdatePrices(storage))
+	router.HandleFunc("POST /admin/products/reindex", api.ReindexProducts(storage))
+	router.HandleFunc("GET /admin/products/{id}", api.GetAdminProductById(storage))
 	router.HandleFunc("PUT /admin/products/{id}", api.UpdateProductById(storage))
+	router.HandleFunc("PATCH /admin/products/{id}", api.PatchProductById(storage))
 	router.HandleFunc("DELETE /admin/products/{id}", api.DeleteProductById(storage))
+	router.HandleFunc("GET /admin/products/trash", api.GetDeletedProducts(storage))
+	router.HandleFunc("POST /admin/products/{id}/restore", api.RestoreDeletedProduct(storage))
+	router.HandleFunc("GET /admin/products/{id}/history", api.GetProductHistory(storage))
+	router.HandleFunc("POST /admin/products/{id}/restore/{revision}", api.RestoreProductRevision(storage))
+
+	router.HandleFunc("GET /admin/jobs/{id}", api.GetJob(storage))
+	router.HandleFunc("POST /admin/jobs/{id}/cancel", api.CancelJob(storage))
+
+	router.HandleFunc("GET /admin/change-requests", api.GetChangeRequests(storage))
+	router.HandleFunc("POST /admin/change-requests/{id}/approve", api.ApproveChangeRequest(storage))
+	router.HandleFunc("POST /admin/change-requests/{id}/reject", api.RejectChangeRequest(storage))
+
+	router.HandleFunc("POST /admin/products/{id}/images", api.UploadProductImages(storage, blobStore, cfg.Blob.MaxImageSize))
+	router.HandleFunc("PUT /admin/products/{id}/images", api.ReorderProductImages(storage))
+	if local, ok := blobStore.(*blob.Local); ok {
+		router.Handle("GET /media/", http.StripPrefix("/media", local.Handler()))
+	}
+
+	router.HandleFunc("POST /admin/products/{id}/variants", api.CreateVariant(storage))
+	router.HandleFunc("PUT /admin/products/{id}/variants/{variant_id}", api.UpdateVariant(storage))
+	router.HandleFunc("DELETE /admin/products/{id}/variants/{variant_id}", api.DeleteVariant(storage))
+
+	router.HandleFunc("GET /admin/products/{id}/inventory", api.GetInventoryMovements(storage))
+	router.HandleFunc("POST /admin/products/{id}/inventory", api.PostInventoryMovement(storage))
+	router.HandleFunc("POST /admin/inventory/reconcile", api.ReconcileInventory(storage))
+	router.HandleFunc("GET /admin/warehouses", api.GetWarehouses(storage))
+	router.HandleFunc("POST /admin/warehouses", api.CreateWarehouse(storage))
+	router.HandleFunc("PUT /admin/warehouses/{id}", api.UpdateWarehouse(storage))
+	router.HandleFunc("POST /admin/warehouses/transfers", api.TransferStock(storage))
+	router.HandleFunc("GET /admin/products/{id}/stock", api.GetProductStock(storage))
 	
+	router.HandleFunc("GET /admin/products/{id}/prices", api.GetProductPrices(storage))
+	router.HandleFunc("PUT /admin/products/{id}/prices/{currency}", api.SetProductPriceIn(storage))
+	router.HandleFunc("DELETE /admin/products/{id}/prices/{currency}", api.DeleteProductPriceIn(storage))
+
+	router.HandleFunc("GET /admin/exchange-rates", api.GetExchangeRates(storage))
+	router.HandleFunc("PUT /admin/exchange-rates/{from}/{to}", api.SetExchangeRate(storage))
+	router.HandleFunc("DELETE /admin/exchange-rates/{from}/{to}", api.DeleteExchangeRate(storage))
+
+	router.HandleFunc("GET /admin/products/{id}/sales", api.GetSalePrices(storage))
+	router.HandleFunc("POST /admin/products/{id}/sales", api.CreateSalePrice(storage))
+	router.HandleFunc("DELETE /admin/products/{id}/sales/{sale_id}", api.DeleteSalePrice(storage))
+
+	router.HandleFunc("GET /admin/user-groups", api.GetUserGroups(storage))
+	router.HandleFunc("POST /admin/user-groups", api.CreateUserGroup(storage))
+	router.HandleFunc("PUT /admin/user-groups/{id}/members/{user_id}", api.AddUserToGroup(storage))
+	router.HandleFunc("DELETE /admin/user-groups/{id}/members/{user_id}", api.RemoveUserFromGroup(storage))
+
+	router.HandleFunc("GET /admin/price-lists", api.GetPriceLists(storage))
+	router.HandleFunc("POST /admin/price-lists", api.CreatePriceList(storage))
+	router.HandleFunc("PUT /admin/price-lists/{id}", api.UpdatePriceList(storage))
+	router.HandleFunc("DELETE /admin/price-lists/{id}", api.DeletePriceList(storage))
+
+	router.HandleFunc("GET /admin/promotions", api.GetPromotions(storage))
+	router.HandleFunc("POST /admin/promotions", api.CreatePromotion(storage))
+	router.HandleFunc("PUT /admin/promotions/{id}", api.UpdatePromotion(storage))
+	router.HandleFunc("DELETE /admin/promotions/{id}", api.DeletePromotion(storage))
+
+	router.HandleFunc("PUT /admin/categories/{id}/attributes", api.SetAttributeSchema(storage))
+	router.HandleFunc("GET /categories/{id}/attributes", api.GetAttributeSchema(storage))
+
 	router.HandleFunc("GET /products/{id}", api.GetProductById(storage))
+	router.HandleFunc("GET /products/{id}/price-history", api.GetPriceHistory(storage))
 	router.HandleFunc("GET /products/", api.GetProducts(storage))
 	router.HandleFunc("GET /products/default", api.GetDefaultProducts(storage))
 	router.HandleFunc("GET /products/filtered", api.GetFilteredProducts(storage))
 	router.HandleFunc("GET /products/search", api.SearcProducts(storage))
 
+	router.HandleFunc("GET /feeds/google.xml", api.GoogleFeed(storage, cfg.Feed))
+
 	router.HandleFunc("POST /user", api.CreateNewUser(storage))
+	router.HandleFunc("POST /login", api.Login(storage, guestCarts, sessions))
 
 	router.HandleFunc("POST /wishlist/{user_id}/{product_id}", api.AddToWishList(storage))
 	router.HandleFunc("DELETE /wishlist/{user_id}/{product_id}", api.RemoveFromWishList(storage))
 	router.HandleFunc("GET /wishlist/{user_id}", api.FetchWishListItems(storage))
 
-	router.HandleFunc("POST /cart/{user_id}/{product_id}", api.AddToCart(storage))
-	router.HandleFunc("DELETE /cart/{user_id}/{product_id}", api.RemoveFromCart(storage))
-	router.HandleFunc("GET /cart/{user_id}", api.FetchCartItems(storage))
+	router.HandleFunc("POST /cart/{user_id}/{product_id}", api.AddToCart(storage, api.UserCart))
+	router.HandleFunc("PATCH /cart/{user_id}/{product_id}", api.UpdateCartItem(storage, api.UserCart))
+	router.HandleFunc("DELETE /cart/{user_id}/{product_id}", api.RemoveFromCart(storage, api.UserCart))
+	router.HandleFunc("DELETE /cart/{user_id}", api.ClearCart(storage, api.UserCart))
+	router.HandleFunc("GET /cart/{user_id}", api.FetchCartItems(storage, api.UserCart))
+	router.HandleFunc("POST /cart/{user_id}/reserve", api.ReserveCart(storage, api.UserCart))
+	router.HandleFunc("POST /cart/{user_id}/order", api.PlaceOrder(storage, api.UserCart, cfg.Inventory.Allocation))
+	router.HandleFunc("GET /cart/{user_id}/allocation", api.AllocateCart(storage, api.UserCart, cfg.Inventory.Allocation))
+
+	router.HandleFunc("POST /guest/cart/{product_id}", api.AddToCart(storage, guestCarts.Owner))
+	router.HandleFunc("PATCH /guest/cart/{product_id}", api.UpdateCartItem(storage, guestCarts.Owner))
+	router.HandleFunc("DELETE /guest/cart/{product_id}", api.RemoveFromCart(storage, guestCarts.Owner))
+	router.HandleFunc("DELETE /guest/cart", api.ClearCart(storage, guestCarts.Owner))
+	router.HandleFunc("GET /guest/cart", api.FetchCartItems(storage, guestCarts.Owner))
+	router.HandleFunc("POST /guest/cart/reserve", api.ReserveCart(storage, guestCarts.Owner))
+	router.HandleFunc("POST /guest/cart/order", api.PlaceOrder(storage, guestCarts.Owner, cfg.Inventory.Allocation))
+	router.HandleFunc("GET /guest/cart/allocation", api.AllocateCart(storage, guestCarts.Owner, cfg.Inventory.Allocation))
+	router.HandleFunc("POST /guest/cart/coupon", api.ApplyCoupon(storage, guestCarts.Owner))
+	router.HandleFunc("DELETE /guest/cart/coupon", api.RemoveCoupon(storage, guestCarts.Owner))
+	router.HandleFunc("POST /me/cart/{product_id}", api.AddToCart(storage, api.MeCart))
+	router.HandleFunc("PATCH /me/cart/{product_id}", api.UpdateCartItem(storage, api.MeCart))
+	router.HandleFunc("DELETE /me/cart/{product_id}", api.RemoveFromCart(storage, api.MeCart))
+	router.HandleFunc("DELETE /me/cart", api.ClearCart(storage, api.MeCart))
+	router.HandleFunc("GET /me/cart", api.FetchCartItems(storage, api.MeCart))
+	router.HandleFunc("POST /me/cart/reserve", api.ReserveCart(storage, api.MeCart))
+	router.HandleFunc("POST /me/cart/order", api.PlaceOrder(storage, api.MeCart, cfg.Inventory.Allocation))
+	router.HandleFunc("GET /me/cart/allocation", api.AllocateCart(storage, api.MeCart, cfg.Inventory.Allocation))
+	router.HandleFunc("POST /me/cart/coupon", api.ApplyCoupon(storage, api.MeCart))
+	router.HandleFunc("DELETE /me/cart/coupon", api.RemoveCoupon(storage, api.MeCart))
 	//Server Setup
 	server := http.Server{
 		Addr:    cfg.HTTPServer.Addr,
-		Handler: router,
+		Handler: sessions.Authenticate(router),
 	}
 
 	slog.Info("Server started", slog.String("address", cfg.HTTPServer.Addr))
 
+	//Background Tasks
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
+	var tasks sync.WaitGroup
+
+	tasks.Go(func() {
+		scheduler.Every(tasksCtx, cfg.Catalog.PurgeInterval, "purge deleted products", func(ctx context.Context) error {
+			purged, err := storage.PurgeDeletedProducts(cfg.Catalog.TrashRetention)
+			if purged > 0 {
+				slog.Info("Purged deleted products", slog.Int("count", purged))
+			}
+			return err
+		})
+	})
+
+	tasks.Go(func() {
+		scheduler.Every(tasksCtx, cfg.Catalog.PurgeInterval, "purge guest carts", func(ctx context.Context) error {
+			purged, err := storage.PurgeGuestCarts(cfg.Cart.GuestTTL)
+			if purged > 0 {
+				slog.Info("Purged guest carts", slog.Int("count", purged))
+			}
+			return err
+		})
+	})
+
+	tasks.Go(func() {
+		scheduler.Every(tasksCtx, cfg.Cart.SweepInterval, "release expired reservations", func(ctx context.Context) error {
+			released, err := storage.ReleaseExpiredReservations()
+			if released > 0 {
+				slog.Info("Released expired stock reservations", slog.Int("count", released))
+			}
+			return err
+		})
+	})
+
+	tasks.Go(func() {
+		scheduler.Every(tasksCtx, cfg.Catalog.PublishInterval, "publish scheduled products", func(ctx context.Context) error {
+			published, err := storage.PublishScheduledProducts()
+			if len(published) > 0 {
+				slog.Info("Published scheduled products", slog.Any("productIds", published))
+			}
+			return err
+		})
+	})
+
+	if cfg.Feed.Interval > 0 {
+		tasks.Go(func() {
+			scheduler.Every(tasksCtx, cfg.Feed.Interval, "write google feed", func(ctx context.Context) error {
+				return feed.WriteGoogleFile(storage, cfg.Feed)
+			})
+		})
+	}
+
+	if requeued, err := storage.RequeueRunningJobs(); err != nil {
+		slog.Error("Failed to requeue interrupted jobs", slog.String("error", err.Error()))
+	} else if requeued > 0 {
+		slog.Info("Requeued interrupted jobs", slog.Int("count", requeued))
+	}
+
+	runner := jobs.NewRunner(storage, cfg.Jobs.PollInterval, api.JobHandlers(storage))
+	tasks.Go(func() {
+		runner.Run(tasksCtx, cfg.Jobs.Workers)
+	})
+
 	done := make(chan os.Signal, 1)
 
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		err := server.ListenAndServe()
-		if err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Failed to start server %s\n", err.Error())
 		}
 
@@ -80,6 +262,9 @@ func main() {
 		slog.Error("Failed to shutdown server", slog.String("error", err.Error()))
 	}
 
+	stopTasks()
+	tasks.Wait()
+
 	slog.Info("Server exited properly")
 
 }
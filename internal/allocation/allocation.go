@@ -0,0 +1,91 @@
+// Package allocation decides which warehouses a cart ships from.
+package allocation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// Source is the stock one warehouse holds of a line's item.
+type Source struct {
+	WarehouseId int
+	Location    *modules.Location
+	Quantity    int
+}
+
+// Line is a cart line and the warehouses holding its item.
+type Line struct {
+	Id       int
+	Quantity int
+	Sources  []Source
+}
+
+// Allocate fills each line from its sources, best first, splitting a line
+// across warehouses only when the best cannot fill it alone. With
+// AllocateNearest the best is the closest to origin; warehouses without a
+// location, and every warehouse when origin is nil, rank by stock instead.
+// With AllocateMostStock the best holds the most.
+func Allocate(lines []Line, strategy string, origin *modules.Location) modules.CartAllocation {
+	result := modules.CartAllocation{Strategy: strategy, Allocations: []modules.Allocation{}, Shortfalls: []modules.Shortfall{}}
+
+	for _, line := range lines {
+		sources := rank(line.Sources, strategy, origin)
+
+		remaining := line.Quantity
+		for _, source := range sources {
+			if remaining == 0 {
+				break
+			}
+			take := min(remaining, source.Quantity)
+			if take <= 0 {
+				continue
+			}
+			result.Allocations = append(result.Allocations, modules.Allocation{CartItemId: line.Id, WarehouseId: source.WarehouseId, Quantity: take})
+			remaining -= take
+		}
+		if remaining > 0 {
+			result.Shortfalls = append(result.Shortfalls, modules.Shortfall{CartItemId: line.Id, Quantity: remaining})
+		}
+	}
+
+	return result
+}
+
+// rank orders sources best first without changing the caller's slice.
+func rank(sources []Source, strategy string, origin *modules.Location) []Source {
+	ranked := append([]Source(nil), sources...)
+
+	distance := func(s Source) float64 {
+		if strategy != modules.AllocateNearest || origin == nil || s.Location == nil {
+			return math.Inf(1)
+		}
+		return Distance(*origin, *s.Location)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		di, dj := distance(ranked[i]), distance(ranked[j])
+		if di != dj {
+			return di < dj
+		}
+		if ranked[i].Quantity != ranked[j].Quantity {
+			return ranked[i].Quantity > ranked[j].Quantity
+		}
+		return ranked[i].WarehouseId < ranked[j].WarehouseId
+	})
+
+	return ranked
+}
+
+// Distance is the great-circle distance between two locations, in
+// kilometres.
+func Distance(a, b modules.Location) float64 {
+	const earthRadius = 6371.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,139 @@
+package allocation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+var (
+	mumbai    = &modules.Location{Latitude: 19.0760, Longitude: 72.8777}
+	delhi     = &modules.Location{Latitude: 28.7041, Longitude: 77.1025}
+	bengaluru = &modules.Location{Latitude: 12.9716, Longitude: 77.5946}
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *modules.Location
+		want float64
+	}{
+		{name: "same place", a: mumbai, b: mumbai, want: 0},
+		{name: "mumbai to delhi", a: mumbai, b: delhi, want: 1153},
+		{name: "delhi to mumbai", a: delhi, b: mumbai, want: 1153},
+		{name: "mumbai to bengaluru", a: mumbai, b: bengaluru, want: 845},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(*tt.a, *tt.b); math.Abs(got-tt.want) > 5 {
+				t.Errorf("Distance = %.0f km, want about %.0f km", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name           string
+		lines          []Line
+		strategy       string
+		origin         *modules.Location
+		wantAllocation []modules.Allocation
+		wantShortfall  []modules.Shortfall
+	}{
+		{
+			name:           "nearest warehouse fills the line",
+			lines:          []Line{{Id: 1, Quantity: 3, Sources: []Source{{1, delhi, 10}, {2, bengaluru, 10}}}},
+			strategy:       modules.AllocateNearest,
+			origin:         mumbai,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 2, Quantity: 3}},
+		},
+		{
+			name:     "line split when the nearest runs short",
+			lines:    []Line{{Id: 1, Quantity: 5, Sources: []Source{{1, delhi, 10}, {2, bengaluru, 2}}}},
+			strategy: modules.AllocateNearest,
+			origin:   mumbai,
+			wantAllocation: []modules.Allocation{
+				{CartItemId: 1, WarehouseId: 2, Quantity: 2},
+				{CartItemId: 1, WarehouseId: 1, Quantity: 3},
+			},
+		},
+		{
+			name:           "most stock ignores distance",
+			lines:          []Line{{Id: 1, Quantity: 3, Sources: []Source{{1, delhi, 10}, {2, bengaluru, 4}}}},
+			strategy:       modules.AllocateMostStock,
+			origin:         mumbai,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 1, Quantity: 3}},
+		},
+		{
+			name:           "nearest without an origin ranks by stock",
+			lines:          []Line{{Id: 1, Quantity: 3, Sources: []Source{{1, bengaluru, 4}, {2, delhi, 10}}}},
+			strategy:       modules.AllocateNearest,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 2, Quantity: 3}},
+		},
+		{
+			name:           "warehouses without a location come after located ones",
+			lines:          []Line{{Id: 1, Quantity: 3, Sources: []Source{{1, nil, 50}, {2, delhi, 5}}}},
+			strategy:       modules.AllocateNearest,
+			origin:         mumbai,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 2, Quantity: 3}},
+		},
+		{
+			name:           "equal stock goes to the lowest warehouse id",
+			lines:          []Line{{Id: 1, Quantity: 3, Sources: []Source{{3, nil, 5}, {2, nil, 5}}}},
+			strategy:       modules.AllocateMostStock,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 2, Quantity: 3}},
+		},
+		{
+			name:     "shortfall when stock runs out",
+			lines:    []Line{{Id: 1, Quantity: 8, Sources: []Source{{1, delhi, 3}, {2, bengaluru, 0}, {3, mumbai, 2}}}},
+			strategy: modules.AllocateNearest,
+			origin:   mumbai,
+			wantAllocation: []modules.Allocation{
+				{CartItemId: 1, WarehouseId: 3, Quantity: 2},
+				{CartItemId: 1, WarehouseId: 1, Quantity: 3},
+			},
+			wantShortfall: []modules.Shortfall{{CartItemId: 1, Quantity: 3}},
+		},
+		{
+			name: "lines allocated independently",
+			lines: []Line{
+				{Id: 1, Quantity: 1, Sources: []Source{{1, delhi, 1}}},
+				{Id: 2, Quantity: 2, Sources: nil},
+			},
+			strategy:       modules.AllocateMostStock,
+			wantAllocation: []modules.Allocation{{CartItemId: 1, WarehouseId: 1, Quantity: 1}},
+			wantShortfall:  []modules.Shortfall{{CartItemId: 2, Quantity: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Allocate(tt.lines, tt.strategy, tt.origin)
+			if tt.wantShortfall == nil {
+				tt.wantShortfall = []modules.Shortfall{}
+			}
+			if got.Strategy != tt.strategy {
+				t.Errorf("strategy = %q, want %q", got.Strategy, tt.strategy)
+			}
+			if !reflect.DeepEqual(got.Allocations, tt.wantAllocation) {
+				t.Errorf("allocations = %+v, want %+v", got.Allocations, tt.wantAllocation)
+			}
+			if !reflect.DeepEqual(got.Shortfalls, tt.wantShortfall) {
+				t.Errorf("shortfalls = %+v, want %+v", got.Shortfalls, tt.wantShortfall)
+			}
+		})
+	}
+}
+
+func TestRankKeepsCallerOrder(t *testing.T) {
+	sources := []Source{{1, delhi, 1}, {2, bengaluru, 9}}
+	rank(sources, modules.AllocateMostStock, nil)
+
+	if sources[0].WarehouseId != 1 || sources[1].WarehouseId != 2 {
+		t.Errorf("rank reordered the caller's sources: %+v", sources)
+	}
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+)
+
+// actorFromRequest returns the id of the user making an admin change, taken
+// from the request's session, or 0 when there is none. The X-User-Id header
+// is not trusted: anyone could name another user in it.
+func actorFromRequest(r *http.Request) int {
+	return sessionUser(r)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// heldChange returns the change request when err reports that storage held
+// a change for approval.
+func heldChange(err error) (modules.ChangeRequest, bool) {
+	var held *storage.HeldForApproval
+	if !errors.As(err, &held) {
+		return modules.ChangeRequest{}, false
+	}
+	return held.ChangeRequest, true
+}
+
+// writeHeld answers 202 Accepted when err reports that storage held the
+// change for approval, and reports whether it did.
+func writeHeld(w http.ResponseWriter, err error) bool {
+	cr, ok := heldChange(err)
+	if !ok {
+		return false
+	}
+
+	slog.Info("Change held for approval", slog.String("productId", fmt.Sprint(cr.ProductId)), slog.String("changeRequestId", fmt.Sprint(cr.ChangeRequestId)), slog.String("reason", cr.Reason))
+	response.WriteJson(w, http.StatusAccepted, map[string]any{
+		"message":        "change requires approval by another admin",
+		"change_request": cr,
+	})
+	return true
+}
+
+func GetChangeRequests(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+
+		requests, err := storage.GetChangeRequests(status)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, requests)
+	}
+}
+
+func reviewChangeRequest(storage storage.Storage, approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid change request id")))
+			return
+		}
+
+		reviewerId := actorFromRequest(r)
+		if reviewerId == 0 {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("sign in with POST /login to review change requests")))
+			return
+		}
+
+		var body struct {
+			Note string `json:"note"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		var cr modules.ChangeRequest
+		if approve {
+			cr, err = storage.ApproveChangeRequest(id, reviewerId, body.Note)
+		} else {
+			cr, err = storage.RejectChangeRequest(id, reviewerId, body.Note)
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Reviewed change request", slog.String("changeRequestId", fmt.Sprint(id)), slog.String("status", cr.Status))
+		response.WriteJson(w, http.StatusOK, cr)
+	}
+}
+
+func ApproveChangeRequest(storage storage.Storage) http.HandlerFunc {
+	return reviewChangeRequest(storage, true)
+}
+
+func RejectChangeRequest(storage storage.Storage) http.HandlerFunc {
+	return reviewChangeRequest(storage, false)
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReviewChangeRequestNeedsSession(t *testing.T) {
+	sessions := newTestSessions(t, "secret")
+
+	for _, approve := range []bool{true, false} {
+		handler := sessions.Authenticate(reviewChangeRequest(nil, approve))
+
+		r := httptest.NewRequest(http.MethodPost, "/admin/change-requests/1/approve", nil)
+		r.SetPathValue("id", "1")
+		r.Header.Set("X-User-Id", "1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("approve=%v: status %d, want %d", approve, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
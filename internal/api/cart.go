@@ -11,14 +11,12 @@ import (
 	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
 )
 
-func AddToCart(storage storage.Storage) http.HandlerFunc {
+func AddToCart(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDStr := r.PathValue("user_id")
 		productIDStr := r.PathValue("product_id")
 
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid user_id")))
+		owner, ok := cartOwner(w, r)
+		if !ok {
 			return
 		}
 
@@ -29,17 +27,23 @@ func AddToCart(storage storage.Storage) http.HandlerFunc {
 		}
 
 		var body struct {
-			Quantity int `json:"quantity"`
-			Discount int `json:"discount"` 
+			VariantId int `json:"variant_id"`
+			Quantity  int `json:"quantity"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Quantity <= 0 {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid input")))
 			return
 		}
 
-		cartItemID, err := storage.AddToCart(userID, productID, body.Quantity, body.Discount)
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+		pc.UserId = owner.UserId
+
+		cartItemID, err := storage.AddToCart(owner, productID, body.VariantId, body.Quantity, pc)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return
 		}
 
@@ -50,16 +54,13 @@ func AddToCart(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
-func RemoveFromCart(storage storage.Storage) http.HandlerFunc {
+func RemoveFromCart(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDStr := r.PathValue("user_id")
 		productIDStr := r.PathValue("product_id")
 
-		userId, err := strconv.Atoi(userIDStr)
-		
-		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid user id")))
-			return 
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
 		}
 		
 		productId, err := strconv.Atoi(productIDStr)
@@ -69,8 +70,13 @@ func RemoveFromCart(storage storage.Storage) http.HandlerFunc {
 			return 
 		}
 
-		if err = storage.RemoveFromCart(userId, productId); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		variantId, ok := variantQuery(w, r)
+		if !ok {
+			return
+		}
+
+		if err = storage.RemoveFromCart(owner, productId, variantId); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return 
 		}
 
@@ -78,33 +84,139 @@ func RemoveFromCart(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
-func FetchCartItems(storage storage.Storage) http.HandlerFunc {
+func FetchCartItems(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.Info("Fetching all cart Items")
 
-		userIdstr := r.PathValue("user_id")
-		
-		userId, err := strconv.Atoi(userIdstr)
-		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
-			return 
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
 		}
 		
-		cartItems, products, err := storage.FetchCartItems(userId)
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+		pc.UserId = owner.UserId
+
+		cart, err := storage.GetCart(owner, pc)
 		
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return 
 		}
 		
 		payload := map[string]interface{}{
-			"message": "cart Items fetched successfully!",
+			"message": "cart fetched successfully!",
 			"result": "success", 
-			"cartItems": cartItems,
-			"products": products,
+			"cart": cart,
 		}
 		
 		response.WriteJson(w, http.StatusOK, payload)
 
 	}
-}
\ No newline at end of file
+}
+
+// UpdateCartItem sets a cart line to an absolute quantity; zero removes it.
+func UpdateCartItem(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		ids, ok := pathIds(w, r, "product_id")
+		if !ok {
+			return
+		}
+
+		var body struct {
+			VariantId int  `json:"variant_id"`
+			Quantity  *int `json:"quantity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Quantity == nil || *body.Quantity < 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid input: quantity must be zero or more")))
+			return
+		}
+
+		totals, err := storage.UpdateCartItemQuantity(owner, ids[0], body.VariantId, *body.Quantity)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"message": "cart item updated successfully",
+			"result":  "success",
+			"totals":  totals,
+		})
+	}
+}
+
+func ClearCart(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		if err := storage.ClearCart(owner); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"message": "cart cleared successfully", "result": "success"})
+	}
+}
+
+// ReserveCart renews the stock reservations of every line in the cart, as
+// when checkout starts. It answers 409 when any line can no longer be filled.
+func ReserveCart(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		reservations, err := storage.ReserveCart(owner)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"result":       "success",
+			"reservations": reservations,
+		})
+	}
+}
+
+// PlaceOrder places the cart as an order, turning its reservations into
+// sales from the warehouses AllocateCart would pick, and redeeming its
+// promotions. It takes the same ?strategy=, ?lat= and ?lng=, and answers 409
+// when the cart is empty or any line can no longer be filled.
+func PlaceOrder(storage storage.Storage, cartOwner CartOwnerFunc, strategy string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		chosen, origin, ok := allocationOptions(w, r, strategy)
+		if !ok {
+			return
+		}
+
+		order, err := storage.PlaceOrder(owner, chosen, origin)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Order placed", slog.Int("cartId", order.CartId))
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"result": "success",
+			"order":  order,
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+func SetAttributeSchema(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categoryId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid category id")))
+			return
+		}
+
+		var schema modules.AttributeSchema
+		err = json.NewDecoder(r.Body).Decode(&schema)
+		if errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+			return
+		}
+
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		validate := validator.New()
+		for _, def := range schema {
+			if err := validate.Struct(def); err != nil {
+				validateErrs := err.(validator.ValidationErrors)
+				response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+				return
+			}
+		}
+
+		if err := storage.SetAttributeSchema(categoryId, schema); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, schema)
+	}
+}
+
+func GetAttributeSchema(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categoryId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid category id")))
+			return
+		}
+
+		schema, err := storage.GetAttributeSchema(categoryId)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, schema)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+func productETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// requireIfMatch reads the product version a client last saw from If-Match.
+// It writes 428 when the header is missing and 412 when it is not the strong
+// ETag GetProductById or GetAdminProductById issued; a weak tag cannot guard
+// a write.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		response.WriteJson(w, http.StatusPreconditionRequired, response.GeneralError(fmt.Errorf("If-Match header with the product ETag is required")))
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		response.WriteJson(w, http.StatusPreconditionFailed, response.GeneralError(fmt.Errorf("If-Match does not match the current product version")))
+		return 0, false
+	}
+
+	return version, true
+}
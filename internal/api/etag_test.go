@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion int
+		wantStatus  int
+	}{
+		{name: "strong tag", header: `"3"`, wantVersion: 3, wantStatus: http.StatusOK},
+		{name: "missing", wantStatus: http.StatusPreconditionRequired},
+		{name: "weak tag", header: `W/"3"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "unquoted", header: `3`, wantStatus: http.StatusPreconditionFailed},
+		{name: "unterminated", header: `"3`, wantStatus: http.StatusPreconditionFailed},
+		{name: "not a version", header: `"abc"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "any", header: `*`, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/admin/products/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			version, ok := requireIfMatch(w, r)
+			if ok != (tt.wantStatus == http.StatusOK) || version != tt.wantVersion || w.Code != tt.wantStatus {
+				t.Errorf("requireIfMatch = %d, %v with status %d, want %d with status %d", version, ok, w.Code, tt.wantVersion, tt.wantStatus)
+			}
+		})
+	}
+}
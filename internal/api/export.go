@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nkchakradhari780/catalogServices/internal/export"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// ExportProducts streams every live product matching the /products/filtered
+// parameters as a CSV, NDJSON or XLSX download.
+func ExportProducts(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filters := r.URL.Query()
+		format := filters.Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		filters.Del("format")
+
+		if !export.Supported(format) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("unsupported export format %q, expected csv, ndjson or xlsx", format)))
+			return
+		}
+
+		// The writer is opened on the first row so that a query error can
+		// still be answered with a JSON error instead of a broken file.
+		var writer export.Writer
+		open := func() error {
+			w.Header().Set("Content-Type", export.ContentType(format))
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+			var err error
+			writer, err = export.NewWriter(format, w)
+			return err
+		}
+
+		count := 0
+		err := storage.StreamProducts(filters, func(product modules.Product) error {
+			if writer == nil {
+				if err := open(); err != nil {
+					return err
+				}
+			}
+			count++
+			return writer.Write(product)
+		})
+
+		if err != nil && writer == nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+		if err == nil && writer == nil {
+			err = open()
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			slog.Error("Product export failed", slog.String("format", format), slog.Int("rows", count), slog.String("error", err.Error()))
+			return
+		}
+
+		slog.Info("Exported products", slog.String("format", format), slog.Int("rows", count))
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/feed"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// GoogleFeed serves a Google Merchant Center feed of published, in-stock
+// products, generated from the database on each request.
+func GoogleFeed(storage storage.Storage, feedCfg config.Feed) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+
+		if err := feed.WriteGoogle(w, storage, feedCfg); err != nil {
+			slog.Error("Failed to generate Google feed", slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// cartTokenCookie and cartTokenHeader carry a guest's cart token; the
+// header is for clients that do not keep cookies.
+const (
+	cartTokenCookie = "cart_token"
+	cartTokenHeader = "X-Cart-Token"
+)
+
+// CartOwnerFunc resolves whose cart a request acts on, writing an error
+// response and returning false when it cannot.
+type CartOwnerFunc func(w http.ResponseWriter, r *http.Request) (modules.CartOwner, bool)
+
+// UserCart takes the cart owner from the user_id path value.
+func UserCart(w http.ResponseWriter, r *http.Request) (modules.CartOwner, bool) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid user_id")))
+		return modules.CartOwner{}, false
+	}
+	return modules.CartOwner{UserId: userId}, true
+}
+
+// GuestCarts issues and verifies the signed tokens that identify guest
+// carts. A token is a random guest id and its HMAC-SHA256, so guests cannot
+// pick another guest's id.
+type GuestCarts struct {
+	secret   []byte
+	ttl      time.Duration
+	strategy string
+}
+
+// NewGuestCarts checks the cart config. Without a token secret a random one
+// is used, and guest carts do not survive a restart.
+func NewGuestCarts(cfg config.Cart) (*GuestCarts, error) {
+	switch cfg.MergeStrategy {
+	case modules.CartMergeSum, modules.CartMergeMax, modules.CartMergeUser, modules.CartMergeGuest:
+	default:
+		return nil, fmt.Errorf("unknown cart merge strategy %q", cfg.MergeStrategy)
+	}
+
+	secret := []byte(cfg.TokenSecret)
+	if len(secret) == 0 {
+		slog.Warn("cart.token_secret is not set; guest cart tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &GuestCarts{secret: secret, ttl: cfg.GuestTTL, strategy: cfg.MergeStrategy}, nil
+}
+
+func (g *GuestCarts) sign(guestId string) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(guestId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// guestId returns the guest id of the request's cart token, if it has a
+// valid one.
+func (g *GuestCarts) guestId(r *http.Request) (string, bool) {
+	token := r.Header.Get(cartTokenHeader)
+	if token == "" {
+		if cookie, err := r.Cookie(cartTokenCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	guestId, signature, ok := strings.Cut(token, ".")
+	if !ok || guestId == "" || !hmac.Equal([]byte(signature), []byte(g.sign(guestId))) {
+		return "", false
+	}
+	return guestId, true
+}
+
+// Owner is a CartOwnerFunc for guests. A request without a valid token gets
+// a new one, set as a cookie and returned in the X-Cart-Token header.
+func (g *GuestCarts) Owner(w http.ResponseWriter, r *http.Request) (modules.CartOwner, bool) {
+	if guestId, ok := g.guestId(r); ok {
+		return modules.CartOwner{GuestId: guestId}, true
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		return modules.CartOwner{}, false
+	}
+	guestId := base64.RawURLEncoding.EncodeToString(id)
+	token := guestId + "." + g.sign(guestId)
+
+	http.SetCookie(w, &http.Cookie{Name: cartTokenCookie, Value: token, Path: "/", MaxAge: int(g.ttl.Seconds()), HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	w.Header().Set(cartTokenHeader, token)
+	return modules.CartOwner{GuestId: guestId}, true
+}
+
+// Login checks a user's email and password, starts a session and merges
+// the guest cart of the request's cart token, if any, into the user's cart.
+// The session token is set as the session cookie and returned for clients
+// that send it as a bearer token.
+func Login(storage storage.Storage, guests *GuestCarts, sessions *Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Email    string `json:"email" validate:"required,email"`
+			Password string `json:"password" validate:"required"`
+		}
+		if !decodeValid(w, r, &body) {
+			return
+		}
+
+		// An unknown email and a wrong password get the same answer.
+		userId, hash, err := storage.GetUserCredentials(strings.TrimSpace(body.Email))
+		if err != nil && storageErrorStatus(err) != http.StatusNotFound {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+		if err != nil || !CheckPasswordHash(body.Password, hash) {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("invalid credentials")))
+			return
+		}
+
+		merged := false
+		if guestId, ok := guests.guestId(r); ok {
+			if err := storage.MergeGuestCart(guestId, userId, guests.strategy); err != nil {
+				response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: cartTokenCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			merged = true
+		}
+
+		token, expires := sessions.issue(userId)
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", Expires: expires, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"result":      "success",
+			"user_id":     userId,
+			"token":       token,
+			"expires_at":  expires,
+			"cart_merged": merged,
+		})
+	}
+}
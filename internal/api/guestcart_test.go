@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+func newTestGuestCarts(t *testing.T, secret string) *GuestCarts {
+	t.Helper()
+	guests, err := NewGuestCarts(config.Cart{TokenSecret: secret, GuestTTL: time.Hour, MergeStrategy: modules.CartMergeSum})
+	if err != nil {
+		t.Fatalf("NewGuestCarts: %v", err)
+	}
+	return guests
+}
+
+func TestGuestCartToken(t *testing.T) {
+	guests := newTestGuestCarts(t, "secret")
+	other := newTestGuestCarts(t, "another secret")
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		wantId string
+		wantOk bool
+	}{
+		{name: "header", header: "guest1." + guests.sign("guest1"), wantId: "guest1", wantOk: true},
+		{name: "cookie", cookie: "guest1." + guests.sign("guest1"), wantId: "guest1", wantOk: true},
+		{name: "header wins over cookie", header: "guest1." + guests.sign("guest1"), cookie: "guest2." + guests.sign("guest2"), wantId: "guest1", wantOk: true},
+		{name: "no token"},
+		{name: "other guest's signature", header: "guest2." + guests.sign("guest1")},
+		{name: "signed with another secret", header: "guest1." + other.sign("guest1")},
+		{name: "tampered signature", header: "guest1." + guests.sign("guest1") + "x"},
+		{name: "no signature", header: "guest1"},
+		{name: "empty guest id", header: "." + guests.sign("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/guest/cart", nil)
+			if tt.header != "" {
+				r.Header.Set(cartTokenHeader, tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: cartTokenCookie, Value: tt.cookie})
+			}
+
+			id, ok := guests.guestId(r)
+			if id != tt.wantId || ok != tt.wantOk {
+				t.Errorf("guestId = %q, %v, want %q, %v", id, ok, tt.wantId, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGuestCartOwnerIssuesToken(t *testing.T) {
+	guests := newTestGuestCarts(t, "secret")
+
+	w := httptest.NewRecorder()
+	owner, ok := guests.Owner(w, httptest.NewRequest(http.MethodGet, "/guest/cart", nil))
+	if !ok || owner.GuestId == "" {
+		t.Fatalf("Owner = %+v, %v", owner, ok)
+	}
+
+	token := w.Header().Get(cartTokenHeader)
+	if token != owner.GuestId+"."+guests.sign(owner.GuestId) {
+		t.Fatalf("issued token %q does not sign guest %q", token, owner.GuestId)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/guest/cart", nil)
+	r.Header.Set(cartTokenHeader, token)
+	w = httptest.NewRecorder()
+	again, ok := guests.Owner(w, r)
+	if !ok || again != owner {
+		t.Errorf("Owner with issued token = %+v, %v, want %+v", again, ok, owner)
+	}
+	if w.Header().Get(cartTokenHeader) != "" {
+		t.Errorf("a valid token was replaced")
+	}
+}
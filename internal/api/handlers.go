@@ -6,15 +6,39 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"reflect"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/nkchakradhari780/catalogServices/internal/modules"
 	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/mergepatch"
 	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
 )
 
+// storageErrorStatus maps storage errors caused by bad client input to 400.
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrInvalidAttributes), errors.Is(err, storage.ErrInvalidFilter), errors.Is(err, storage.ErrUnsupportedCurrency),
+		errors.Is(err, storage.ErrVariantRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, storage.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func CreateNewProduct(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -38,9 +62,9 @@ func CreateNewProduct(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		lastId, err := storage.CreateProduct(product.Name, product.Price, product.Stock, product.CategoryID, product.Quantity, product.Brand, product.Images)
+		lastId, err := storage.CreateProduct(product, actorFromRequest(r))
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return
 		}
 
@@ -62,13 +86,44 @@ func GetProductById(storage storage.Storage) http.HandlerFunc {
             response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
             return
         }
-		product, err := storage.GetProductById(id) 
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
+		product, err := storage.GetProductById(id, pc)
 		if err != nil {
 			slog.Error("Error fetching product", slog.String("productId", idStr), slog.String("error", err.Error()))
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		w.Header().Set("ETag", productETag(product.Version))
+		response.WriteJson(w, http.StatusOK, product)
+	}
+}
+
+// GetAdminProductById returns a product whatever its status, with its
+// variants, and its version as the ETag edits send back in If-Match.
+func GetAdminProductById(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
 			return
 		}
 
+		product, err := storage.GetAdminProductById(ids[0])
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+		product.Variants, err = storage.GetVariantsByProductId(ids[0])
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		w.Header().Set("ETag", productETag(product.Version))
 		response.WriteJson(w, http.StatusOK, product)
 	}
 }
@@ -77,10 +132,15 @@ func GetProducts(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.Info("Fetching all products")
 
-		products, err := storage.GetProducts()
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
+		products, err := storage.GetProducts(pc)
 		if err != nil {
 			fmt.Println("Error fetching products:", err)
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return 
 		}
 
@@ -92,10 +152,15 @@ func GetDefaultProducts(storate storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request){
 		slog.Info("Fetching Default Products")
 
-		products, err := storate.GetDefaultProducts()
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
+		products, err := storate.GetDefaultProducts(pc)
 		if err != nil {
 			fmt. Println("Error Fetching products: ", err)
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError((err)))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError((err)))
 			return 
 		}
 
@@ -106,11 +171,17 @@ func GetDefaultProducts(storate storage.Storage) http.HandlerFunc {
 func GetFilteredProducts(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.Info("Fetching Filtered products")
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
 		filters := r.URL.Query()
+		filters.Del("currency")
 
-		products, err := storage.GetFilteredProducts(filters)
+		products, err := storage.GetFilteredProducts(filters, pc)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return 
 		}
 
@@ -128,9 +199,14 @@ func SearcProducts(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		products, err := storage.SearchProducts(qureyStr) // or SearchProductsFTS
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
+		products, err := storage.SearchProducts(qureyStr, pc) // or SearchProductsFTS
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return
 		}
 
@@ -150,6 +226,11 @@ func UpdateProductById(storage storage.Storage) http.HandlerFunc {
 			return 
 		}
 
+		version, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+
 		var product modules.Product
 		err = json.NewDecoder(r.Body).Decode(&product)
 		
@@ -169,17 +250,159 @@ func UpdateProductById(storage storage.Storage) http.HandlerFunc {
 			return 
 		}
 
-		updatedProduct, err := storage.UpdateProductById(id, product.Name, product.Price, product.Stock, product.CategoryID, product.Quantity, product.Brand, product.Images)
+		updatedProduct, err := storage.UpdateProductById(id, version, product, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError((err)))
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError((err)))
 			return 
 		}
 
+		w.Header().Set("ETag", productETag(updatedProduct.Version))
 		response.WriteJson(w, http.StatusOK, updatedProduct)
 
 	}
 }
 
+// patchableFields maps the JSON members accepted by PatchProductById to the
+// modules.Product field validated and the column written.
+var patchableFields = map[string]struct{ field, column string }{
+	"name":        {"Name", "name"},
+	"price":       {"Price", "price"},
+	"stock":       {"Stock", "stock"},
+	"category_id": {"CategoryID", "category_id"},
+	"quantity":    {"Quantity", "quantity"},
+	"brand":       {"Brand", "brand"},
+	"images":      {"Images", "images"},
+	"attributes":  {"Attributes", "attributes"},
+	"status":      {"Status", "status"},
+	"publish_at":  {"PublishAt", "publish_at"},
+	"sku":         {"SKU", "sku"},
+	"external_id": {"ExternalId", "external_id"},
+}
+
+// requiredMembers are the patchable members a product cannot be without, so
+// a patch may change them but not remove them with null.
+var requiredMembers = map[string]bool{
+	"name": true, "price": true, "stock": true, "category_id": true,
+	"quantity": true, "brand": true, "status": true,
+}
+
+// PatchProductById applies an RFC 7396 JSON Merge Patch. Only the members
+// present in the patch are validated and written.
+func PatchProductById(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		version, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != "application/merge-patch+json" && contentType != "application/json" {
+			response.WriteJson(w, http.StatusUnsupportedMediaType, response.GeneralError(fmt.Errorf("content type must be application/merge-patch+json")))
+			return
+		}
+
+		var patch map[string]any
+		err = json.NewDecoder(r.Body).Decode(&patch)
+		if errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+			return
+		}
+
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("patch must be a JSON object: %w", err)))
+			return
+		}
+
+		var fields []string
+		for name := range patch {
+			f, ok := patchableFields[name]
+			if !ok {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("%s cannot be patched", name)))
+				return
+			}
+			if patch[name] == nil && requiredMembers[name] {
+				response.WriteJson(w, http.StatusUnprocessableEntity, response.GeneralError(fmt.Errorf("%s is required and cannot be removed", name)))
+				return
+			}
+			fields = append(fields, f.field)
+		}
+
+		// publish_at is required by status, so check it whenever status changes
+		if _, ok := patch["status"]; ok {
+			fields = append(fields, "PublishAt")
+		}
+
+		// Money is validated through its members, and has no optional ones
+		if price, ok := patch["price"]; ok {
+			members, _ := price.(map[string]any)
+			for member, value := range members {
+				if value == nil {
+					response.WriteJson(w, http.StatusUnprocessableEntity, response.GeneralError(fmt.Errorf("price.%s is required and cannot be removed", member)))
+					return
+				}
+			}
+			fields = append(fields, "Price.Amount", "Price.Currency")
+		}
+
+		current, err := storage.GetAdminProductById(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		if current.Version != version {
+			response.WriteJson(w, http.StatusPreconditionFailed, response.GeneralError(fmt.Errorf("product %d is at version %d", id, current.Version)))
+			return
+		}
+
+		var document map[string]any
+		data, _ := json.Marshal(current)
+		json.Unmarshal(data, &document)
+
+		data, _ = json.Marshal(mergepatch.Apply(document, patch))
+		var product modules.Product
+		if err := json.Unmarshal(data, &product); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		if err := validator.New().StructPartial(product, fields...); err != nil {
+			validateErrs := err.(validator.ValidationErrors)
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+			return
+		}
+
+		patched := reflect.ValueOf(product)
+		changes := make(map[string]any, len(patch))
+		for name := range patch {
+			f := patchableFields[name]
+			changes[f.column] = patched.FieldByName(f.field).Interface()
+		}
+
+		updatedProduct, err := storage.PatchProductById(id, version, changes, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Patched Product", slog.String("productId", fmt.Sprint(id)))
+		w.Header().Set("ETag", productETag(updatedProduct.Version))
+		response.WriteJson(w, http.StatusOK, updatedProduct)
+	}
+}
+
 func DeleteProductById(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Deleting Product")
@@ -190,8 +413,17 @@ func DeleteProductById(storage storage.Storage) http.HandlerFunc {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 			return 
 		}
-		if err = storage.DeleteProductById(id); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		version, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		err = storage.DeleteProductById(id, version, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return 
 		}
 
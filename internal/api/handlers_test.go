@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPatchProductRejectsNullRequiredMembers(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "price", body: `{"price": null}`},
+		{name: "price amount", body: `{"price": {"amount": null}}`},
+		{name: "price currency", body: `{"price": {"currency": null}}`},
+		{name: "name", body: `{"name": null}`},
+		{name: "status", body: `{"status": null, "brand": "Acme"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/admin/products/1", strings.NewReader(tt.body))
+			r.SetPathValue("id", "1")
+			r.Header.Set("If-Match", `"1"`)
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+
+			w := httptest.NewRecorder()
+			PatchProductById(nil)(w, r)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+			}
+		})
+	}
+}
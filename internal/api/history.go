@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+func GetProductHistory(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		revisions, err := storage.GetProductHistory(id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, revisions)
+	}
+}
+
+func RestoreProductRevision(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		revisionId, err := strconv.Atoi(r.PathValue("revision"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid revision id")))
+			return
+		}
+
+		product, err := storage.RestoreProductRevision(id, revisionId, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Restored Product", slog.String("productId", fmt.Sprint(id)), slog.String("revision", fmt.Sprint(revisionId)))
+		w.Header().Set("ETag", productETag(product.Version))
+		response.WriteJson(w, http.StatusOK, product)
+	}
+}
+
+func GetDeletedProducts(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("Fetching deleted products")
+
+		products, err := storage.GetDeletedProducts()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, products)
+	}
+}
+
+func RestoreDeletedProduct(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		product, err := storage.RestoreDeletedProduct(id, actorFromRequest(r))
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Restored Product from trash", slog.String("productId", fmt.Sprint(id)))
+		w.Header().Set("ETag", productETag(product.Version))
+		response.WriteJson(w, http.StatusOK, product)
+	}
+}
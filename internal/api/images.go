@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/nkchakradhari780/catalogServices/internal/blob"
+	"github.com/nkchakradhari780/catalogServices/internal/imaging"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// maxImagesPerUpload caps the files accepted by one upload request.
+const maxImagesPerUpload = 10
+
+// imageExtensions maps the sniffed content types we accept to file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type uploadedImage struct {
+	name        string
+	data        []byte
+	contentType string
+}
+
+// readUploadedImages reads the "images" files of a multipart form, checking
+// each one's size and, by its magic bytes, that it really is an image.
+func readUploadedImages(w http.ResponseWriter, r *http.Request, maxSize int64) ([]uploadedImage, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize*maxImagesPerUpload+1<<20)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("upload exceeds %d bytes", tooLarge.Limit)))
+			return nil, false
+		}
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid multipart form: %w", err)))
+		return nil, false
+	}
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("no files in the images field")))
+		return nil, false
+	}
+	if len(files) > maxImagesPerUpload {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("at most %d images can be uploaded at once", maxImagesPerUpload)))
+		return nil, false
+	}
+
+	images := make([]uploadedImage, 0, len(files))
+	for _, fh := range files {
+		if fh.Size > maxSize {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("%s exceeds %d bytes", fh.Filename, maxSize)))
+			return nil, false
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return nil, false
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+		f.Close()
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return nil, false
+		}
+
+		contentType := http.DetectContentType(data)
+		if _, ok := imageExtensions[contentType]; !ok {
+			response.WriteJson(w, http.StatusUnsupportedMediaType, response.GeneralError(fmt.Errorf("%s is not a JPEG, PNG, GIF or WebP image", fh.Filename)))
+			return nil, false
+		}
+
+		if imaging.Decodable(contentType) {
+			width, height, err := imaging.Size(data)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("%s is not a valid image: %w", fh.Filename, err)))
+				return nil, false
+			}
+			if width*height > imaging.MaxPixels {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("%s is %dx%d, larger than %d pixels", fh.Filename, width, height, imaging.MaxPixels)))
+				return nil, false
+			}
+		}
+
+		images = append(images, uploadedImage{name: fh.Filename, data: data, contentType: contentType})
+	}
+
+	return images, true
+}
+
+// storeImage stores an upload and its renditions next to it, recording every
+// key written in keys so a failed request can remove them again.
+func storeImage(ctx context.Context, store blob.Store, productId int, upload uploadedImage, keys *[]string) (modules.ProductImage, error) {
+	base := fmt.Sprintf("products/%d/%s", productId, randomName())
+
+	key := base + imageExtensions[upload.contentType]
+	if err := store.Put(ctx, key, upload.data, upload.contentType); err != nil {
+		return modules.ProductImage{}, err
+	}
+	*keys = append(*keys, key)
+
+	image := modules.ProductImage{URL: store.URL(key)}
+	if !imaging.Decodable(upload.contentType) {
+		return image, nil
+	}
+
+	image.Width, image.Height, _ = imaging.Size(upload.data)
+	image.Renditions = make(map[string]modules.ImageRendition, len(imaging.Renditions))
+	for _, r := range imaging.Renditions {
+		image.Renditions[r.Name] = modules.ImageRendition{URL: image.URL, Width: image.Width, Height: image.Height}
+	}
+
+	renditions, err := imaging.Render(upload.data, upload.contentType)
+	if err != nil {
+		return modules.ProductImage{}, err
+	}
+
+	for _, rendition := range renditions {
+		key := fmt.Sprintf("%s_%s%s", base, rendition.Name, imageExtensions[rendition.ContentType])
+		if err := store.Put(ctx, key, rendition.Data, rendition.ContentType); err != nil {
+			return modules.ProductImage{}, err
+		}
+		*keys = append(*keys, key)
+
+		image.Renditions[rendition.Name] = modules.ImageRendition{URL: store.URL(key), Width: rendition.Width, Height: rendition.Height}
+	}
+
+	return image, nil
+}
+
+func randomName() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UploadProductImages stores uploaded images with thumbnail, medium and large
+// renditions and adds them to the product, at the optional "position" form
+// field or at the end.
+func UploadProductImages(storage storage.Storage, store blob.Store, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		version, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		uploads, ok := readUploadedImages(w, r, maxSize)
+		if !ok {
+			return
+		}
+
+		product, err := storage.GetAdminProductById(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		position := len(product.Images)
+		if value := r.FormValue("position"); value != "" {
+			position, err = strconv.Atoi(value)
+			if err != nil || position < 0 || position > len(product.Images) {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("position must be between 0 and %d", len(product.Images))))
+				return
+			}
+		}
+
+		var keys []string
+		var added []modules.ProductImage
+		cleanup := func() {
+			for _, key := range keys {
+				if err := store.Delete(r.Context(), key); err != nil {
+					slog.Error("Failed to delete uploaded image", slog.String("key", key), slog.String("error", err.Error()))
+				}
+			}
+		}
+
+		for _, upload := range uploads {
+			image, err := storeImage(r.Context(), store, id, upload, &keys)
+			if err != nil {
+				cleanup()
+				response.WriteJson(w, http.StatusBadGateway, response.GeneralError(fmt.Errorf("error storing %s: %w", upload.name, err)))
+				return
+			}
+			added = append(added, image)
+		}
+
+		images := slices.Insert(slices.Clone(product.Images), position, added...)
+		updated, err := storage.PatchProductById(id, version, map[string]any{"images": images}, actorFromRequest(r))
+		if err != nil {
+			cleanup()
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Uploaded product images", slog.String("productId", fmt.Sprint(id)), slog.Int("count", len(added)))
+		w.Header().Set("ETag", productETag(updated.Version))
+		response.WriteJson(w, http.StatusCreated, updated)
+	}
+}
+
+// ReorderProductImages sets the order of a product's images. The body must
+// list exactly the product's current image URLs (the originals).
+func ReorderProductImages(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		version, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Images []string `json:"images"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		product, err := storage.GetAdminProductById(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		current, requested := modules.ImageURLs(product.Images), slices.Clone(body.Images)
+		slices.Sort(current)
+		slices.Sort(requested)
+		if !slices.Equal(current, requested) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("images must list the product's current image URLs in the new order")))
+			return
+		}
+
+		byURL := make(map[string]modules.ProductImage, len(product.Images))
+		for _, image := range product.Images {
+			byURL[image.URL] = image
+		}
+		images := make([]modules.ProductImage, len(body.Images))
+		for i, url := range body.Images {
+			images[i] = byURL[url]
+		}
+
+		updated, err := storage.PatchProductById(id, version, map[string]any{"images": images}, actorFromRequest(r))
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		w.Header().Set("ETag", productETag(updated.Version))
+		response.WriteJson(w, http.StatusOK, updated)
+	}
+}
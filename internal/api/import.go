@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/importer"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// importBatchSize is the number of rows upserted per transaction.
+const importBatchSize = 500
+
+// importFormat picks the import format from ?format= or the Content-Type.
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl":
+		return "ndjson"
+	}
+	return ""
+}
+
+// ImportProducts streams a CSV or NDJSON file of products and upserts them by
+// SKU or external id, returning a per-row report. With ?dry_run=true rows are
+// validated and matched but nothing is saved. With ?async=true the upload is
+// spooled to disk and imported by a background job instead.
+func ImportProducts(storage storage.Storage, jobsCfg config.Jobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+		format := importFormat(r)
+
+		if async {
+			if !importer.Supported(format) {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("unsupported import format %q", format)))
+				return
+			}
+
+			path, err := spoolUpload(jobsCfg.SpoolDir, r.Body)
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+				return
+			}
+
+			enqueueJob(w, r, storage, modules.JobImport, importJobParams{Path: path, Format: format, DryRun: dryRun})
+			return
+		}
+
+		reader, err := importer.NewReader(format, r.Body)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		report, err := importProducts(r.Context(), storage, reader, dryRun, actorFromRequest(r), nil)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Imported products", slog.Bool("dryRun", dryRun), slog.Int("total", report.Total), slog.Int("failed", report.Failed))
+		response.WriteJson(w, http.StatusOK, report)
+	}
+}
+
+// spoolUpload copies an upload into dir so a job can read it later.
+func spoolUpload(dir string, body io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating spool directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, "import-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating spool file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("error saving upload: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// importProducts validates rows from reader and upserts them in batches,
+// stopping between rows once ctx is done. progress, when set, is called
+// after each batch with the rows handled so far.
+func importProducts(ctx context.Context, storage storage.Storage, reader importer.Reader, dryRun bool, actorId int, progress func(done int) error) (modules.ImportReport, error) {
+	report := modules.ImportReport{DryRun: dryRun, Rows: []modules.ImportResult{}}
+	validate := validator.New()
+
+	var batch []modules.ImportRow
+	var lines []int
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		results, err := storage.ImportProducts(batch, dryRun, actorId)
+		if err != nil {
+			return err
+		}
+
+		for i, result := range results {
+			result.Line = lines[i]
+			report.Add(result)
+		}
+		batch, lines = batch[:0], lines[:0]
+
+		if progress != nil {
+			return progress(report.Total)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("error reading import file: %w", err)
+		}
+
+		result := modules.ImportResult{Line: row.Line, SKU: row.Product.SKU, ExternalId: row.Product.ExternalId, Action: modules.ImportFailed}
+
+		switch {
+		case row.Err != nil:
+			result.Error = row.Err.Error()
+		case row.Product.SKU == "" && row.Product.ExternalId == "":
+			result.Error = "sku or external_id is required"
+		default:
+			if err := validate.Struct(row.Product); err != nil {
+				result.Error = response.ValidationError(err.(validator.ValidationErrors)).Error
+			}
+		}
+
+		if result.Error != "" {
+			report.Add(result)
+			continue
+		}
+
+		batch = append(batch, modules.ImportRow{Product: row.Product, Fields: row.Fields})
+		lines = append(lines, row.Line)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Line < report.Rows[j].Line })
+	return report, nil
+}
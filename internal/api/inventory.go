@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// PostInventoryMovement records a receipt, sale, adjustment or return of a
+// product's stock, or of a variant's when variant_id is set, in the
+// warehouse given by warehouse_id or in those the storage picks.
+func PostInventoryMovement(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var movement modules.InventoryMovement
+		if !decodeValid(w, r, &movement) {
+			return
+		}
+		movement.Reason = strings.TrimSpace(movement.Reason)
+		if movement.Type != modules.MovementAdjustment && movement.Quantity < 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("quantity of a %s must be positive; use an adjustment to correct stock", movement.Type)))
+			return
+		}
+
+		posted, err := storage.PostInventoryMovement(ids[0], movement, actorFromRequest(r))
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, posted)
+	}
+}
+
+func GetInventoryMovements(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		movements, err := storage.GetInventoryMovements(ids[0])
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, movements)
+	}
+}
+
+// ReconcileInventory lists products and variants whose stock differs from
+// their ledger. With ?fix=true their stock is set to the ledger's.
+func ReconcileInventory(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fix, _ := strconv.ParseBool(r.URL.Query().Get("fix"))
+
+		discrepancies, err := storage.ReconcileInventory(fix)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"fixed":         fix,
+			"discrepancies": discrepancies,
+		})
+	}
+}
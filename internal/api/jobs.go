@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/nkchakradhari780/catalogServices/internal/importer"
+	"github.com/nkchakradhari780/catalogServices/internal/jobs"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// importJobParams describes an import whose upload was spooled to disk.
+type importJobParams struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type bulkPriceParams struct {
+	Targets []modules.PriceTarget `json:"targets"`
+}
+
+type bulkPriceFailure struct {
+	ProductId int    `json:"product_id"`
+	Error     string `json:"error"`
+}
+
+// bulkPriceHeld is a price change held for a second admin's approval.
+type bulkPriceHeld struct {
+	ProductId       int `json:"product_id"`
+	ChangeRequestId int `json:"change_request_id"`
+}
+
+type bulkPriceResult struct {
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Held      []bulkPriceHeld    `json:"held"`
+	Failed    []bulkPriceFailure `json:"failed"`
+}
+
+// JobHandlers returns the background job implementations by job type.
+func JobHandlers(storage storage.Storage) map[string]jobs.Handler {
+	return map[string]jobs.Handler{
+		modules.JobImport:    importJob(storage),
+		modules.JobBulkPrice: bulkPriceJob(storage),
+		modules.JobReindex:   reindexJob(storage),
+	}
+}
+
+func jobActor(job modules.Job) int {
+	if job.CreatedBy == nil {
+		return 0
+	}
+	return *job.CreatedBy
+}
+
+func importJob(storage storage.Storage) jobs.Handler {
+	return func(ctx context.Context, job modules.Job, progress jobs.Progress) (any, error) {
+		var params importJobParams
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return nil, fmt.Errorf("invalid job params: %w", err)
+		}
+
+		file, err := os.Open(params.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening import file: %w", err)
+		}
+		defer file.Close()
+
+		reader, err := importer.NewReader(params.Format, file)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := importProducts(ctx, storage, reader, params.DryRun, jobActor(job), func(done int) error {
+			return progress(done, 0)
+		})
+
+		// Keep the upload while the job may still be retried after a restart.
+		if ctx.Err() == nil {
+			os.Remove(params.Path)
+		}
+		return report, err
+	}
+}
+
+func bulkPriceJob(storage storage.Storage) jobs.Handler {
+	return func(ctx context.Context, job modules.Job, progress jobs.Progress) (any, error) {
+		var params bulkPriceParams
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return nil, fmt.Errorf("invalid job params: %w", err)
+		}
+
+		result := bulkPriceResult{Held: []bulkPriceHeld{}, Failed: []bulkPriceFailure{}}
+		total := len(params.Targets)
+
+		for i, target := range params.Targets {
+			if i%50 == 0 {
+				if err := progress(i, total); err != nil {
+					return result, err
+				}
+			}
+
+			if target.To < 0 {
+				result.Failed = append(result.Failed, bulkPriceFailure{target.ProductId, "price would be negative"})
+				continue
+			}
+
+			changed, err := storage.SetProductPrice(target.ProductId, target.To, jobActor(job))
+			cr, held := heldChange(err)
+			switch {
+			case held:
+				result.Held = append(result.Held, bulkPriceHeld{target.ProductId, cr.ChangeRequestId})
+			case err != nil:
+				result.Failed = append(result.Failed, bulkPriceFailure{target.ProductId, err.Error()})
+			case changed:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+
+		progress(total, total)
+		return result, nil
+	}
+}
+
+func reindexJob(storage storage.Storage) jobs.Handler {
+	return func(ctx context.Context, job modules.Job, progress jobs.Progress) (any, error) {
+		if err := progress(0, 1); err != nil {
+			return nil, err
+		}
+
+		if err := storage.ReindexProducts(); err != nil {
+			return nil, err
+		}
+
+		progress(1, 1)
+		return map[string]string{"table": "products"}, nil
+	}
+}
+
+// enqueueJob queues a job and answers 202 Accepted with its status URL.
+func enqueueJob(w http.ResponseWriter, r *http.Request, storage storage.Storage, jobType string, params any) {
+	job, err := storage.CreateJob(jobType, params, actorFromRequest(r))
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		return
+	}
+
+	slog.Info("Job queued", slog.String("jobId", fmt.Sprint(job.JobId)), slog.String("type", jobType))
+	w.Header().Set("Location", fmt.Sprintf("/admin/jobs/%d", job.JobId))
+	response.WriteJson(w, http.StatusAccepted, job)
+}
+
+// BulkUpdatePrices queues a price change for every product matching the
+// /products/filtered parameters in "filters". "amount" is in minor units of
+// each product's currency. New prices are worked out now, so re-running the
+// job after a restart sets the same values.
+func BulkUpdatePrices(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Filters map[string]string `json:"filters"`
+			Percent float64           `json:"percent"`
+			Amount  int64             `json:"amount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if body.Percent == 0 && body.Amount == 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("percent or amount is required")))
+			return
+		}
+
+		filters := make(map[string][]string, len(body.Filters))
+		for k, v := range body.Filters {
+			filters[k] = []string{v}
+		}
+
+		products, err := storage.GetAdminProducts(filters)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+		if len(products) == 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("no products match the filters")))
+			return
+		}
+
+		targets := make([]modules.PriceTarget, len(products))
+		for i, product := range products {
+			price := int64(math.Round(float64(product.Price.Amount)*(1+body.Percent/100))) + body.Amount
+			targets[i] = modules.PriceTarget{ProductId: product.ProductId, From: product.Price.Amount, To: price}
+		}
+
+		enqueueJob(w, r, storage, modules.JobBulkPrice, bulkPriceParams{Targets: targets})
+	}
+}
+
+func ReindexProducts(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enqueueJob(w, r, storage, modules.JobReindex, map[string]any{})
+	}
+}
+
+func GetJob(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid job id")))
+			return
+		}
+
+		job, err := storage.GetJob(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, job)
+	}
+}
+
+func CancelJob(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid job id")))
+			return
+		}
+
+		job, err := storage.CancelJob(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		// A job cancelled before it started never gets to clean up its upload.
+		if job.Status == modules.JobCancelled && job.Type == modules.JobImport {
+			var params importJobParams
+			if json.Unmarshal(job.Params, &params) == nil && params.Path != "" {
+				os.Remove(params.Path)
+			}
+		}
+
+		slog.Info("Job cancellation requested", slog.String("jobId", fmt.Sprint(id)), slog.String("status", job.Status))
+		response.WriteJson(w, http.StatusAccepted, job)
+	}
+}
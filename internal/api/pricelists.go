@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// decodeValid decodes the request body into v and validates it, writing a
+// 400 response and returning false when either fails.
+func decodeValid(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return false
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return false
+	}
+
+	if err := validator.New().Struct(v); err != nil {
+		validateErrs := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+		return false
+	}
+	return true
+}
+
+// checkWindow rejects a validity window that ends before it starts.
+func checkWindow(w http.ResponseWriter, startsAt *time.Time, endsAt *time.Time) bool {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("ends_at must be after starts_at")))
+		return false
+	}
+	return true
+}
+
+// pathIds parses the named path values as integer ids.
+func pathIds(w http.ResponseWriter, r *http.Request, names ...string) ([]int, bool) {
+	ids := make([]int, len(names))
+	for i, name := range names {
+		id, err := strconv.Atoi(r.PathValue(name))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid %s", name)))
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+func CreateUserGroup(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var group modules.UserGroup
+		if !decodeValid(w, r, &group) {
+			return
+		}
+
+		created, err := storage.CreateUserGroup(strings.TrimSpace(group.Name))
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+func GetUserGroups(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := storage.GetUserGroups()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, groups)
+	}
+}
+
+func AddUserToGroup(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id", "user_id")
+		if !ok {
+			return
+		}
+
+		if err := storage.AddUserToGroup(ids[0], ids[1]); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
+
+func RemoveUserFromGroup(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id", "user_id")
+		if !ok {
+			return
+		}
+
+		if err := storage.RemoveUserFromGroup(ids[0], ids[1]); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
+
+func decodePriceList(w http.ResponseWriter, r *http.Request) (modules.PriceList, bool) {
+	var list modules.PriceList
+	if !decodeValid(w, r, &list) || !checkWindow(w, list.StartsAt, list.EndsAt) {
+		return list, false
+	}
+	return list, true
+}
+
+func CreatePriceList(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, ok := decodePriceList(w, r)
+		if !ok {
+			return
+		}
+
+		created, err := storage.CreatePriceList(list, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+func UpdatePriceList(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		list, ok := decodePriceList(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := storage.UpdatePriceList(ids[0], list, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, updated)
+	}
+}
+
+func GetPriceLists(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lists, err := storage.GetPriceLists()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, lists)
+	}
+}
+
+func DeletePriceList(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := storage.DeletePriceList(ids[0]); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
+
+func CreateSalePrice(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var sale modules.SalePrice
+		if !decodeValid(w, r, &sale) || !checkWindow(w, &sale.StartsAt, sale.EndsAt) {
+			return
+		}
+
+		created, err := storage.CreateSalePrice(ids[0], sale, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+func GetSalePrices(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		sales, err := storage.GetSalePrices(ids[0])
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, sales)
+	}
+}
+
+func DeleteSalePrice(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id", "sale_id")
+		if !ok {
+			return
+		}
+
+		if err := storage.DeleteSalePrice(ids[0], ids[1]); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// priceContext reads the currency prices should be shown in from the
+// currency query parameter or, failing that, the X-Currency header, and the
+// shopper from the session. It writes a 400 response and returns false when the
+// currency is not an ISO 4217 code.
+func priceContext(w http.ResponseWriter, r *http.Request) (modules.PriceContext, bool) {
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = r.Header.Get("X-Currency")
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+
+	if currency != "" {
+		if err := validator.New().Var(currency, "iso4217"); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid currency %q", currency)))
+			return modules.PriceContext{}, false
+		}
+	}
+
+	return modules.PriceContext{Currency: currency, UserId: actorFromRequest(r)}, true
+}
+
+// GetPriceHistory lists a product's price changes, newest first. The
+// currency parameter limits it to prices in that currency.
+func GetPriceHistory(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+
+		history, err := storage.GetPriceHistory(id, pc.Currency)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, history)
+	}
+}
+
+func GetProductPrices(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		prices, err := storage.GetProductPrices(id)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, prices)
+	}
+}
+
+// SetProductPriceIn sets a product's explicit price in the currency named in
+// the path. Body: {"amount": 1999} in that currency's minor units.
+func SetProductPriceIn(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		var body struct {
+			Amount *int64 `json:"amount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Amount == nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("amount is required")))
+			return
+		}
+
+		price := modules.Money{Amount: *body.Amount, Currency: strings.ToUpper(r.PathValue("currency"))}
+		if err := validator.New().Struct(price); err != nil {
+			validateErrs := err.(validator.ValidationErrors)
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+			return
+		}
+
+		err = storage.SetProductPriceIn(id, price, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Product price set", slog.Int("productId", id), slog.String("price", price.String()))
+		response.WriteJson(w, http.StatusOK, price)
+	}
+}
+
+func DeleteProductPriceIn(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		if err := storage.DeleteProductPriceIn(id, strings.ToUpper(r.PathValue("currency"))); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
+
+func GetExchangeRates(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rates, err := storage.GetExchangeRates()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, rates)
+	}
+}
+
+// SetExchangeRate sets how many units of {to} one unit of {from} buys.
+// Body: {"rate": 0.012}.
+func SetExchangeRate(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Rate float64 `json:"rate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid input")))
+			return
+		}
+
+		rate := modules.ExchangeRate{
+			From: strings.ToUpper(r.PathValue("from")),
+			To:   strings.ToUpper(r.PathValue("to")),
+			Rate: body.Rate,
+		}
+		if err := validator.New().Struct(rate); err != nil {
+			validateErrs := err.(validator.ValidationErrors)
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+			return
+		}
+
+		saved, err := storage.SetExchangeRate(rate.From, rate.To, rate.Rate)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Exchange rate set", slog.String("from", saved.From), slog.String("to", saved.To), slog.Float64("rate", saved.Rate))
+		response.WriteJson(w, http.StatusOK, saved)
+	}
+}
+
+func DeleteExchangeRate(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := storage.DeleteExchangeRate(r.PathValue("from"), r.PathValue("to")); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
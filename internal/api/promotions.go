@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// decodePromotion decodes and validates a promotion. Codes are matched
+// case-insensitively, so they are stored upper-cased.
+func decodePromotion(w http.ResponseWriter, r *http.Request) (modules.Promotion, bool) {
+	var promo modules.Promotion
+	if !decodeValid(w, r, &promo) || !checkWindow(w, promo.StartsAt, promo.EndsAt) {
+		return promo, false
+	}
+
+	promo.Name = strings.TrimSpace(promo.Name)
+	promo.Code = strings.ToUpper(strings.TrimSpace(promo.Code))
+
+	if promo.Amount != nil && promo.MinSpend != nil && promo.Amount.Currency != promo.MinSpend.Currency {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("amount and min_spend must be in the same currency")))
+		return promo, false
+	}
+	return promo, true
+}
+
+func CreatePromotion(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promo, ok := decodePromotion(w, r)
+		if !ok {
+			return
+		}
+
+		created, err := storage.CreatePromotion(promo)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+func UpdatePromotion(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		promo, ok := decodePromotion(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := storage.UpdatePromotion(ids[0], promo)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, updated)
+	}
+}
+
+func GetPromotions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promos, err := storage.GetPromotions()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, promos)
+	}
+}
+
+func DeletePromotion(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := storage.DeletePromotion(ids[0]); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
+
+// ApplyCoupon puts the code in the body on the caller's cart and returns the
+// recomputed totals. An empty code removes the current coupon.
+func ApplyCoupon(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil && !errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		var totals modules.CartTotals
+		if code := strings.ToUpper(strings.TrimSpace(body.Code)); code != "" {
+			totals, err = storage.ApplyCoupon(owner, code)
+		} else {
+			totals, err = storage.RemoveCoupon(owner)
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, totals)
+	}
+}
+
+func RemoveCoupon(storage storage.Storage, cartOwner CartOwnerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		totals, err := storage.RemoveCoupon(owner)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, totals)
+	}
+}
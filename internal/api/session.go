@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// sessionCookie carries the session token Login issues; clients without
+// cookies send it as "Authorization: Bearer <token>" instead.
+const sessionCookie = "session"
+
+// sessionKey is the request context key of the signed-in user's id.
+type sessionKey struct{}
+
+// Sessions issues and verifies the session tokens Login hands out. A token
+// is a user id, its expiry and their HMAC-SHA256, so a client cannot act as
+// another user by naming their id.
+type Sessions struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSessions checks the auth config. Without a session secret a random one
+// is used, and sessions do not survive a restart.
+func NewSessions(cfg config.Auth) (*Sessions, error) {
+	if cfg.SessionTTL <= 0 {
+		return nil, fmt.Errorf("auth.session_ttl must be positive")
+	}
+
+	secret := []byte(cfg.SessionSecret)
+	if len(secret) == 0 {
+		slog.Warn("auth.session_secret is not set; sessions will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Sessions{secret: secret, ttl: cfg.SessionTTL}, nil
+}
+
+func (s *Sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue returns a session token for userId and when it expires.
+func (s *Sessions) issue(userId int) (string, time.Time) {
+	expires := time.Now().Add(s.ttl).Truncate(time.Second)
+	payload := strconv.Itoa(userId) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + s.sign(payload), expires
+}
+
+// verify returns the user id of an unexpired token signed by s.
+func (s *Sessions) verify(token string) (int, bool) {
+	cut := strings.LastIndexByte(token, '.')
+	if cut < 0 {
+		return 0, false
+	}
+	payload, signature := token[:cut], token[cut+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return 0, false
+	}
+
+	id, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return 0, false
+	}
+	userId, err := strconv.Atoi(id)
+	if err != nil || userId <= 0 {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return 0, false
+	}
+	return userId, true
+}
+
+// requestToken returns the session token a request carries, if any.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Authenticate puts the user of the request's session on its context.
+// Requests without a token go through anonymously; a token that is forged
+// or expired is answered with 401.
+func (s *Sessions) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userId, ok := s.verify(token)
+		if !ok {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("invalid or expired session, sign in again")))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, userId)))
+	})
+}
+
+// sessionUser returns the signed-in user of a request that went through
+// Authenticate, or 0 for an anonymous one.
+func sessionUser(r *http.Request) int {
+	userId, _ := r.Context().Value(sessionKey{}).(int)
+	return userId
+}
+
+// MeCart is a CartOwnerFunc for /me/cart: the signed-in user's cart. It
+// answers 401 without a session.
+func MeCart(w http.ResponseWriter, r *http.Request) (modules.CartOwner, bool) {
+	userId := sessionUser(r)
+	if userId == 0 {
+		response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("sign in with POST /login first")))
+		return modules.CartOwner{}, false
+	}
+	return modules.CartOwner{UserId: userId}, true
+}
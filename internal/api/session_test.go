@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+)
+
+func newTestSessions(t *testing.T, secret string) *Sessions {
+	t.Helper()
+	sessions, err := NewSessions(config.Auth{SessionSecret: secret, SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSessions: %v", err)
+	}
+	return sessions
+}
+
+func TestSessionToken(t *testing.T) {
+	sessions := newTestSessions(t, "secret")
+	other := newTestSessions(t, "another secret")
+
+	token, _ := sessions.issue(7)
+	otherToken, _ := other.issue(7)
+	expiredPayload := "7." + strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name   string
+		token  string
+		wantId int
+		wantOk bool
+	}{
+		{name: "issued token", token: token, wantId: 7, wantOk: true},
+		{name: "signed with another secret", token: otherToken},
+		{name: "other user's id", token: "8" + token[1:]},
+		{name: "expired", token: expiredPayload + "." + sessions.sign(expiredPayload)},
+		{name: "no signature", token: "7.9999999999"},
+		{name: "garbage", token: "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := sessions.verify(tt.token)
+			if id != tt.wantId || ok != tt.wantOk {
+				t.Errorf("verify = %d, %v, want %d, %v", id, ok, tt.wantId, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	sessions := newTestSessions(t, "secret")
+	token, _ := sessions.issue(7)
+
+	var seen int
+	handler := sessions.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = sessionUser(r)
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		cookie     string
+		wantStatus int
+		wantUser   int
+	}{
+		{name: "bearer token", header: "Bearer " + token, wantStatus: http.StatusOK, wantUser: 7},
+		{name: "session cookie", cookie: token, wantStatus: http.StatusOK, wantUser: 7},
+		{name: "forged token", header: "Bearer 1" + token[1:], wantStatus: http.StatusUnauthorized},
+		{name: "X-User-Id is not trusted", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = 0
+			r := httptest.NewRequest(http.MethodGet, "/me/cart", nil)
+			r.Header.Set("X-User-Id", "1")
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus || seen != tt.wantUser {
+				t.Errorf("status %d, user %d, want %d, %d", w.Code, seen, tt.wantStatus, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+func decodeVariant(w http.ResponseWriter, r *http.Request) (modules.ProductVariant, bool) {
+	var variant modules.ProductVariant
+
+	err := json.NewDecoder(r.Body).Decode(&variant)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return variant, false
+	}
+
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return variant, false
+	}
+
+	if err := validator.New().Struct(variant); err != nil {
+		validateErrs := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+		return variant, false
+	}
+
+	return variant, true
+}
+
+// variantQuery reads the optional ?variant_id= of cart and wishlist
+// requests; 0 means the product itself.
+func variantQuery(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("variant_id")
+	if value == "" {
+		return 0, true
+	}
+	variantId, err := strconv.Atoi(value)
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid variant_id")))
+		return 0, false
+	}
+	return variantId, true
+}
+
+func CreateVariant(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		variant, ok := decodeVariant(w, r)
+		if !ok {
+			return
+		}
+
+		variantId, err := storage.CreateVariant(productId, variant.SKU, variant.Options, variant.Price, variant.Stock, variant.Images, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		slog.Info("Creating New Variant", slog.String("productId", fmt.Sprint(productId)), slog.String("variantId", fmt.Sprint(variantId)))
+		response.WriteJson(w, http.StatusCreated, map[string]any{"message": "Variant created successfully", "variant_id": variantId})
+	}
+}
+
+func UpdateVariant(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		variantId, err := strconv.Atoi(r.PathValue("variant_id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid variant id")))
+			return
+		}
+
+		variant, ok := decodeVariant(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := storage.UpdateVariant(productId, variantId, variant.SKU, variant.Options, variant.Price, variant.Stock, variant.Images, actorFromRequest(r))
+		if writeHeld(w, err) {
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, updated)
+	}
+}
+
+func DeleteVariant(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid product id")))
+			return
+		}
+
+		variantId, err := strconv.Atoi(r.PathValue("variant_id"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid variant id")))
+			return
+		}
+
+		if err := storage.DeleteVariant(productId, variantId); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"result": "success"})
+	}
+}
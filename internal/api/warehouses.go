@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+	"github.com/nkchakradhari780/catalogServices/internal/utils/response"
+)
+
+// IsAllocationStrategy reports whether strategy names a way of allocating
+// carts to warehouses.
+func IsAllocationStrategy(strategy string) bool {
+	return strategy == modules.AllocateNearest || strategy == modules.AllocateMostStock
+}
+
+func decodeWarehouse(w http.ResponseWriter, r *http.Request) (modules.Warehouse, bool) {
+	var warehouse modules.Warehouse
+	if !decodeValid(w, r, &warehouse) {
+		return warehouse, false
+	}
+	warehouse.Code = strings.ToUpper(strings.TrimSpace(warehouse.Code))
+	warehouse.Name = strings.TrimSpace(warehouse.Name)
+	return warehouse, true
+}
+
+func CreateWarehouse(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		warehouse, ok := decodeWarehouse(w, r)
+		if !ok {
+			return
+		}
+
+		created, err := storage.CreateWarehouse(warehouse)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, created)
+	}
+}
+
+func UpdateWarehouse(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		warehouse, ok := decodeWarehouse(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := storage.UpdateWarehouse(ids[0], warehouse)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, updated)
+	}
+}
+
+func GetWarehouses(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		warehouses, err := storage.GetWarehouses()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, warehouses)
+	}
+}
+
+// GetProductStock lists what each warehouse holds of a product and its
+// variants.
+func GetProductStock(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := pathIds(w, r, "id")
+		if !ok {
+			return
+		}
+
+		stock, err := storage.GetProductStock(ids[0])
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, stock)
+	}
+}
+
+// TransferStock moves stock between warehouses. It answers 409 when the
+// source warehouse holds too little.
+func TransferStock(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var transfer modules.StockTransfer
+		if !decodeValid(w, r, &transfer) {
+			return
+		}
+		transfer.Reason = strings.TrimSpace(transfer.Reason)
+
+		movements, err := storage.TransferStock(transfer, actorFromRequest(r))
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, movements)
+	}
+}
+
+// allocationOptions reads the allocation strategy from ?strategy=, falling
+// back to strategy, and the customer's location from ?lat= and ?lng=,
+// writing a 400 response when either is invalid.
+func allocationOptions(w http.ResponseWriter, r *http.Request, strategy string) (string, *modules.Location, bool) {
+	query := r.URL.Query()
+	if s := query.Get("strategy"); s != "" {
+		if !IsAllocationStrategy(s) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("strategy must be %s or %s", modules.AllocateNearest, modules.AllocateMostStock)))
+			return "", nil, false
+		}
+		strategy = s
+	}
+
+	var origin *modules.Location
+	if query.Has("lat") || query.Has("lng") {
+		lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+		lng, lngErr := strconv.ParseFloat(query.Get("lng"), 64)
+		if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("lat and lng must both be valid coordinates")))
+			return "", nil, false
+		}
+		origin = &modules.Location{Latitude: lat, Longitude: lng}
+	}
+
+	return strategy, origin, true
+}
+
+// AllocateCart plans which warehouses ship the cart. ?strategy= overrides
+// the configured strategy, and ?lat= and ?lng= give the customer's location
+// for the nearest strategy.
+func AllocateCart(storage storage.Storage, cartOwner CartOwnerFunc, strategy string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := cartOwner(w, r)
+		if !ok {
+			return
+		}
+
+		chosen, origin, ok := allocationOptions(w, r, strategy)
+		if !ok {
+			return
+		}
+
+		plan, err := storage.AllocateCart(owner, chosen, origin)
+		if err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"result":     "success",
+			"allocation": plan,
+		})
+	}
+}
@@ -31,7 +31,16 @@ func AddToWishList(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		wishListId, err := storage.AddToWishList(user_id, product_id)
+		variant_id := 0
+		if variant_id_str := r.URL.Query().Get("variant_id"); variant_id_str != "" {
+			variant_id, err = strconv.Atoi(variant_id_str)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid variant_id")))
+				return
+			}
+		}
+
+		wishListId, err := storage.AddToWishList(user_id, product_id, variant_id)
 
 		if err != nil {
 			if err.Error() == "product already added to wish list" {
@@ -68,8 +77,13 @@ func RemoveFromWishList(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err = storage.RemoveFromWishList(userId, productId); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		variantId, ok := variantQuery(w, r)
+		if !ok {
+			return
+		}
+
+		if err = storage.RemoveFromWishList(userId, productId, variantId); err != nil {
+			response.WriteJson(w, storageErrorStatus(err), response.GeneralError(err))
 			return
 		}
 
@@ -88,7 +102,13 @@ func FetchWishListItems(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		wishListItems, products, err := storage.FetchWishListItems(userId)
+		pc, ok := priceContext(w, r)
+		if !ok {
+			return
+		}
+		pc.UserId = userId
+
+		wishListItems, products, err := storage.FetchWishListItems(userId, pc)
 
 		if err != nil {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
@@ -0,0 +1,57 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+)
+
+// Store keeps uploaded files such as product images. Keys are slash-separated
+// paths like "products/12/3f9c.jpg".
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public address a stored key is served from.
+	URL(key string) string
+	// Key is the inverse of URL. It reports false for URLs this store did
+	// not issue.
+	Key(url string) (string, bool)
+}
+
+// New returns the backend named by cfg.Backend: "local" or "s3".
+func New(cfg config.Blob) (Store, error) {
+	switch cfg.Backend {
+	case "local", "":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "/media"
+		}
+		return NewLocal(cfg.Dir, baseURL), nil
+	case "s3":
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("blob storage s3 needs an endpoint and a bucket")
+		}
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = strings.TrimRight(cfg.Endpoint, "/") + "/" + cfg.Bucket
+		}
+		return NewS3(cfg.Endpoint, cfg.Region, cfg.Bucket, cfg.AccessKey, cfg.SecretKey, baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown blob storage backend %q, expected local or s3", cfg.Backend)
+	}
+}
+
+// joinURL and splitURL map keys to URLs under a base URL.
+func joinURL(baseURL string, key string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + key
+}
+
+func splitURL(baseURL string, url string) (string, bool) {
+	prefix := strings.TrimRight(baseURL, "/") + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -21,11 +22,78 @@ type Database struct {
     SSLMode  string `yaml:"sslmode" env:"DATABASE_SSLMODE" env-default:"disable"`
 }
 
+type Catalog struct {
+    TrashRetention  time.Duration `yaml:"trash_retention" env:"CATALOG_TRASH_RETENTION" env-default:"720h"`
+    PurgeInterval   time.Duration `yaml:"purge_interval" env:"CATALOG_PURGE_INTERVAL" env-default:"1h"`
+    PublishInterval time.Duration `yaml:"publish_interval" env:"CATALOG_PUBLISH_INTERVAL" env-default:"1m"`
+    Currency        string        `yaml:"currency" env:"CATALOG_CURRENCY" env-default:"INR"`
+}
+
+type Approval struct {
+    PriceChangePercent float64       `yaml:"price_change_percent" env:"APPROVAL_PRICE_CHANGE_PERCENT" env-default:"30"`
+    RequireForDelete   bool          `yaml:"require_for_delete" env:"APPROVAL_REQUIRE_FOR_DELETE" env-default:"false"`
+    MassDeleteCount    int           `yaml:"mass_delete_count" env:"APPROVAL_MASS_DELETE_COUNT" env-default:"10"`
+    MassDeleteWindow   time.Duration `yaml:"mass_delete_window" env:"APPROVAL_MASS_DELETE_WINDOW" env-default:"1h"`
+}
+
+type Jobs struct {
+    Workers      int           `yaml:"workers" env:"JOBS_WORKERS" env-default:"2"`
+    PollInterval time.Duration `yaml:"poll_interval" env:"JOBS_POLL_INTERVAL" env-default:"2s"`
+    SpoolDir     string        `yaml:"spool_dir" env:"JOBS_SPOOL_DIR" env-default:"tmp/jobs"`
+}
+
+type Feed struct {
+    BaseURL  string        `yaml:"base_url" env:"FEED_BASE_URL" env-default:"http://localhost:8081"`
+    Title    string        `yaml:"title" env:"FEED_TITLE" env-default:"Catalog"`
+    Path     string        `yaml:"path" env:"FEED_PATH" env-default:"tmp/feeds/google.xml"`
+    Interval time.Duration `yaml:"interval" env:"FEED_INTERVAL" env-default:"1h"`
+}
+
+type Blob struct {
+    Backend      string `yaml:"backend" env:"BLOB_BACKEND" env-default:"local"`
+    BaseURL      string `yaml:"base_url" env:"BLOB_BASE_URL"`
+    Dir          string `yaml:"dir" env:"BLOB_DIR" env-default:"tmp/media"`
+    Endpoint     string `yaml:"endpoint" env:"BLOB_ENDPOINT"`
+    Region       string `yaml:"region" env:"BLOB_REGION" env-default:"us-east-1"`
+    Bucket       string `yaml:"bucket" env:"BLOB_BUCKET"`
+    AccessKey    string `yaml:"access_key" env:"BLOB_ACCESS_KEY"`
+    SecretKey    string `yaml:"secret_key" env:"BLOB_SECRET_KEY"`
+    MaxImageSize int64  `yaml:"max_image_size" env:"BLOB_MAX_IMAGE_SIZE" env-default:"10485760"`
+}
+
+type Cart struct {
+    TaxPercent       float64       `yaml:"tax_percent" env:"CART_TAX_PERCENT" env-default:"0"`
+    PricesIncludeTax bool          `yaml:"prices_include_tax" env:"CART_PRICES_INCLUDE_TAX" env-default:"true"`
+    ShippingFee      int64         `yaml:"shipping_fee" env:"CART_SHIPPING_FEE" env-default:"0"`
+    FreeShippingOver int64         `yaml:"free_shipping_over" env:"CART_FREE_SHIPPING_OVER" env-default:"0"`
+    TokenSecret      string        `yaml:"token_secret" env:"CART_TOKEN_SECRET"`
+    GuestTTL         time.Duration `yaml:"guest_ttl" env:"CART_GUEST_TTL" env-default:"720h"`
+    MergeStrategy    string        `yaml:"merge_strategy" env:"CART_MERGE_STRATEGY" env-default:"sum"`
+    ReservationTTL   time.Duration `yaml:"reservation_ttl" env:"CART_RESERVATION_TTL" env-default:"15m"`
+    SweepInterval    time.Duration `yaml:"sweep_interval" env:"CART_SWEEP_INTERVAL" env-default:"1m"`
+}
+
+type Auth struct {
+    SessionSecret string        `yaml:"session_secret" env:"AUTH_SESSION_SECRET"`
+    SessionTTL    time.Duration `yaml:"session_ttl" env:"AUTH_SESSION_TTL" env-default:"24h"`
+}
+
+type Inventory struct {
+    Allocation string `yaml:"allocation" env:"INVENTORY_ALLOCATION" env-default:"most_stock"`
+}
 
 type Config struct {
     Env        string     `yaml:"env" env:"ENV" env-required:"true" env-default:"production"`
     HTTPServer HTTPServer `yaml:"http_server" env-required:"true"`
     Database   Database   `yaml:"database" env-required:"true"`
+    Catalog    Catalog    `yaml:"catalog"`
+    Approval   Approval   `yaml:"approval"`
+    Jobs       Jobs       `yaml:"jobs"`
+    Feed       Feed       `yaml:"feed"`
+    Blob       Blob       `yaml:"blob"`
+    Cart       Cart       `yaml:"cart"`
+    Inventory  Inventory  `yaml:"inventory"`
+    Auth       Auth       `yaml:"auth"`
 }
 
 
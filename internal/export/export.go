@@ -0,0 +1,131 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// Columns are the exported product columns. Apart from product_id they match
+// what the CSV importer reads, so an export can be edited and imported back.
+var Columns = []string{"product_id", "sku", "external_id", "name", "price", "currency", "stock", "category_id", "quantity", "brand", "images", "attributes", "status", "publish_at"}
+
+// Writer writes products to an export file one at a time. Close must be
+// called to finish the file.
+type Writer interface {
+	Write(product modules.Product) error
+	Close() error
+}
+
+// Supported reports whether NewWriter accepts format.
+func Supported(format string) bool {
+	switch format {
+	case "csv", "ndjson", "xlsx":
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// NewWriter returns a Writer for format "csv", "ndjson" or "xlsx".
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "xlsx":
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q, expected csv, ndjson or xlsx", format)
+	}
+}
+
+// cell is one exported value; numeric cells are typed as numbers in XLSX.
+type cell struct {
+	value   string
+	numeric bool
+}
+
+// record returns product's values in Columns order.
+func record(product modules.Product) []cell {
+	attributes := ""
+	if len(product.Attributes) > 0 {
+		data, _ := json.Marshal(product.Attributes)
+		attributes = string(data)
+	}
+
+	publishAt := ""
+	if product.PublishAt != nil {
+		publishAt = product.PublishAt.Format(time.RFC3339)
+	}
+
+	return []cell{
+		{strconv.Itoa(product.ProductId), true},
+		{product.SKU, false},
+		{product.ExternalId, false},
+		{product.Name, false},
+		{strconv.FormatInt(product.Price.Amount, 10), true},
+		{product.Price.Currency, false},
+		{strconv.Itoa(product.Stock), true},
+		{product.CategoryID, false},
+		{strconv.Itoa(product.Quantity), true},
+		{product.Brand, false},
+		{strings.Join(modules.ImageURLs(product.Images), "|"), false},
+		{attributes, false},
+		{product.Status, false},
+		{publishAt, false},
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(product modules.Product) error {
+	cells := record(product)
+	values := make([]string, len(cells))
+	for i, cell := range cells {
+		values[i] = cell.value
+	}
+	return c.w.Write(values)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(product modules.Product) error {
+	return n.enc.Encode(product)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
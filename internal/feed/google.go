@@ -0,0 +1,121 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// feedFilters selects the products a shopping feed lists: published and in
+// stock.
+var feedFilters = map[string][]string{"status": {modules.StatusPublished}, "stock_gt": {"0"}}
+
+// Google Merchant Center allows up to ten additional images per item.
+const maxAdditionalImages = 10
+
+// googleItem is one <item> of a Google Merchant Center RSS 2.0 feed.
+type googleItem struct {
+	XMLName          xml.Name `xml:"item"`
+	Id               string   `xml:"g:id"`
+	Title            string   `xml:"g:title"`
+	Description      string   `xml:"g:description"`
+	Link             string   `xml:"g:link"`
+	ImageLink        string   `xml:"g:image_link,omitempty"`
+	AdditionalImages []string `xml:"g:additional_image_link,omitempty"`
+	Availability     string   `xml:"g:availability"`
+	Price            string   `xml:"g:price"`
+	Brand            string   `xml:"g:brand"`
+	MPN              string   `xml:"g:mpn,omitempty"`
+	Condition        string   `xml:"g:condition"`
+	ProductType      string   `xml:"g:product_type"`
+}
+
+func newGoogleItem(cfg config.Feed, product modules.Product) googleItem {
+	item := googleItem{
+		Id:           fmt.Sprint(product.ProductId),
+		Title:        product.Name,
+		Description:  product.Name,
+		Link:         fmt.Sprintf("%s/products/%d", strings.TrimRight(cfg.BaseURL, "/"), product.ProductId),
+		Availability: "in_stock",
+		Price:        product.Price.String(),
+		Brand:        product.Brand,
+		MPN:          product.SKU,
+		Condition:    "new",
+		ProductType:  product.CategoryID,
+	}
+
+	if len(product.Images) > 0 {
+		urls := modules.ImageURLs(product.Images)
+		item.ImageLink = urls[0]
+		item.AdditionalImages = urls[1:min(len(urls), maxAdditionalImages+1)]
+	}
+
+	return item
+}
+
+// WriteGoogle streams a Google Merchant Center feed of published, in-stock
+// products to w.
+func WriteGoogle(w io.Writer, storage storage.Storage, cfg config.Feed) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprint(bw, xml.Header+`<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0"><channel>`)
+	enc := xml.NewEncoder(bw)
+	channel := [][2]string{{"title", cfg.Title}, {"link", cfg.BaseURL}, {"description", cfg.Title + " product feed"}}
+	for _, field := range channel {
+		if err := enc.EncodeElement(field[1], xml.StartElement{Name: xml.Name{Local: field[0]}}); err != nil {
+			return err
+		}
+	}
+
+	err := storage.StreamProducts(feedFilters, func(product modules.Product) error {
+		return enc.Encode(newGoogleItem(cfg, product))
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(bw, `</channel></rss>`)
+	return bw.Flush()
+}
+
+// WriteGoogleFile writes the feed to cfg.Path. The file is written under a
+// temporary name and renamed into place, so readers never see a partial feed.
+func WriteGoogleFile(storage storage.Storage, cfg config.Feed) error {
+	dir := filepath.Dir(cfg.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating feed directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(cfg.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating feed file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error creating feed file: %w", err)
+	}
+
+	if err := WriteGoogle(tmp, storage, cfg); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing feed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing feed: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), cfg.Path); err != nil {
+		return fmt.Errorf("error replacing feed: %w", err)
+	}
+
+	return nil
+}
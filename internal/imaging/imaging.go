@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Rendition is a named size an uploaded image is scaled down to, fitting
+// within MaxSize pixels on its longer side.
+type Rendition struct {
+	Name    string
+	MaxSize int
+}
+
+// Renditions are generated for every decodable upload.
+var Renditions = []Rendition{
+	{"thumbnail", 150},
+	{"medium", 600},
+	{"large", 1200},
+}
+
+// MaxPixels bounds the dimensions of images we are willing to decode.
+const MaxPixels = 50_000_000
+
+// Output is one encoded rendition.
+type Output struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Decodable reports whether renditions can be made from contentType. WebP
+// has no decoder in the standard library and is stored as uploaded.
+func Decodable(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Size returns an image's dimensions without decoding its pixels.
+func Size(data []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Render decodes an image and returns the renditions smaller than it.
+// JPEGs stay JPEG; PNGs and GIFs (first frame) become PNG to keep
+// transparency.
+func Render(data []byte, contentType string) ([]Output, error) {
+	var src image.Image
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("cannot decode %s", contentType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	var outputs []Output
+	for _, r := range Renditions {
+		width, height := fit(bounds.Dx(), bounds.Dy(), r.MaxSize)
+		if width == bounds.Dx() && height == bounds.Dy() {
+			continue
+		}
+
+		scaled := resize(rgba, width, height)
+
+		var buf bytes.Buffer
+		outType := contentType
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			outType = "image/png"
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s rendition: %w", r.Name, err)
+		}
+
+		outputs = append(outputs, Output{Name: r.Name, Data: buf.Bytes(), ContentType: outType, Width: width, Height: height})
+	}
+
+	return outputs, nil
+}
+
+// fit scales width x height down to fit within maxSize, keeping the aspect
+// ratio. Images already small enough are returned unchanged.
+func fit(width int, height int, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// resize downscales src with a box filter: each output pixel is the average
+// of the source pixels it covers.
+func resize(src *image.RGBA, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := range height {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := range width {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+
+	return dst
+}
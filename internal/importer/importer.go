@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// Row is one decoded input record. Fields lists the product fields the
+// record supplied, by their JSON names. Err is set when the record could not
+// be turned into a product; the reader carries on with the next record.
+type Row struct {
+	Line    int
+	Product modules.Product
+	Fields  []string
+	Err     error
+}
+
+// fieldNames maps input column names to the product fields they set.
+var fieldNames = map[string]string{
+	"sku": "sku", "external_id": "external_id", "name": "name",
+	"price": "price", "currency": "price", "stock": "stock",
+	"category_id": "category_id", "quantity": "quantity", "brand": "brand",
+	"images": "images", "attributes": "attributes", "status": "status",
+	"publish_at": "publish_at",
+}
+
+// productFields returns the product fields set by the given input columns,
+// sorted and without duplicates.
+func productFields(columns []string) []string {
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, column := range columns {
+		if field, ok := fieldNames[column]; ok && !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Reader streams product rows from an import file. Next returns io.EOF after
+// the last row.
+type Reader interface {
+	Next() (Row, error)
+}
+
+// Supported reports whether NewReader accepts format.
+func Supported(format string) bool {
+	switch format {
+	case "csv", "ndjson", "jsonl":
+		return true
+	}
+	return false
+}
+
+// NewReader returns a Reader for format "csv" or "ndjson".
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+
+		header, err := cr.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("csv file is empty")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv header: %w", err)
+		}
+
+		columns := make(map[string]int, len(header))
+		names := make([]string, 0, len(header))
+		for i, name := range header {
+			name = strings.ToLower(strings.TrimSpace(name))
+			columns[name] = i
+			names = append(names, name)
+		}
+		if _, ok := columns["name"]; !ok {
+			return nil, fmt.Errorf("csv header must include a name column")
+		}
+
+		return &csvReader{r: cr, columns: columns, fields: productFields(names), line: 1}, nil
+	case "ndjson", "jsonl":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unsupported import format %q, expected csv or ndjson", format)
+	}
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	fields  []string
+	line    int
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	c.line++
+	row := Row{Line: c.line, Fields: c.fields}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		row.Err = err
+		return row, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(name string) int {
+		value := field(name)
+		if value == "" || row.Err != nil {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			row.Err = fmt.Errorf("%s must be a whole number", name)
+		}
+		return n
+	}
+
+	product := modules.Product{
+		SKU:        field("sku"),
+		ExternalId: field("external_id"),
+		Name:       field("name"),
+		Price:      modules.Money{Amount: int64(number("price")), Currency: field("currency")},
+		Stock:      number("stock"),
+		CategoryID: field("category_id"),
+		Quantity:   number("quantity"),
+		Brand:      field("brand"),
+		Status:     field("status"),
+	}
+
+	if images := field("images"); images != "" {
+		for _, url := range strings.Split(images, "|") {
+			product.Images = append(product.Images, modules.ProductImage{URL: url})
+		}
+	}
+
+	if attributes := field("attributes"); attributes != "" && row.Err == nil {
+		if err := json.Unmarshal([]byte(attributes), &product.Attributes); err != nil {
+			row.Err = fmt.Errorf("attributes must be a JSON object")
+		}
+	}
+
+	if publishAt := field("publish_at"); publishAt != "" && row.Err == nil {
+		t, err := time.Parse(time.RFC3339, publishAt)
+		if err != nil {
+			row.Err = fmt.Errorf("publish_at must be an RFC 3339 timestamp")
+		}
+		product.PublishAt = &t
+	}
+
+	row.Product = product
+	return row, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Next() (Row, error) {
+	for n.scanner.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := Row{Line: n.line}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			row.Err = fmt.Errorf("invalid JSON: %w", err)
+			return row, nil
+		}
+		if err := json.Unmarshal([]byte(text), &row.Product); err != nil {
+			row.Err = fmt.Errorf("invalid JSON: %w", err)
+			return row, nil
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		row.Fields = productFields(keys)
+		return row, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRowFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   []string
+	}{
+		{
+			name:   "csv header",
+			format: "csv",
+			input:  "SKU, name ,price,currency,unknown\nA-1,Mug,500,EUR,x\n",
+			want:   []string{"name", "price", "sku"},
+		},
+		{
+			name:   "ndjson keys",
+			format: "ndjson",
+			input:  `{"sku":"A-1","name":"Mug","status":"draft","extra":1}` + "\n",
+			want:   []string{"name", "sku", "status"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			row, err := reader.Next()
+			if err != nil || row.Err != nil {
+				t.Fatalf("Next: %v %v", err, row.Err)
+			}
+			if !reflect.DeepEqual(row.Fields, tt.want) {
+				t.Errorf("Fields = %v, want %v", row.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// ErrCancelled is returned by Progress once the job's cancellation has been
+// requested.
+var ErrCancelled = errors.New("job cancelled")
+
+// Progress records how far a job has got. Handlers call it regularly and stop
+// when it returns an error: ErrCancelled after a cancel request, or the
+// context's error when the server is shutting down.
+type Progress func(done int, total int) error
+
+// Handler runs one job and returns its result. A job may be run again from
+// the start after a restart, so handlers must be safe to repeat.
+type Handler func(ctx context.Context, job modules.Job, progress Progress) (any, error)
+
+// Runner claims queued jobs from storage and runs them on a pool of workers.
+type Runner struct {
+	storage      storage.Storage
+	pollInterval time.Duration
+	handlers     map[string]Handler
+}
+
+func NewRunner(storage storage.Storage, pollInterval time.Duration, handlers map[string]Handler) *Runner {
+	return &Runner{storage: storage, pollInterval: pollInterval, handlers: handlers}
+}
+
+// Run starts workers and blocks until ctx is cancelled and every worker has
+// stopped. Jobs interrupted by the shutdown go back to the queue.
+func (r *Runner) Run(ctx context.Context, workers int) {
+	slog.Info("Job workers started", slog.Int("workers", workers))
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() { r.work(ctx) })
+	}
+	wg.Wait()
+
+	slog.Info("Job workers stopped")
+}
+
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := r.storage.ClaimJob()
+		if err == nil {
+			r.runJob(ctx, job)
+			continue
+		}
+
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.Error("Failed to claim job", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+func (r *Runner) runJob(ctx context.Context, job modules.Job) {
+	logger := slog.With(slog.Int("jobId", job.JobId), slog.String("type", job.Type))
+
+	handler, ok := r.handlers[job.Type]
+	if !ok {
+		r.finish(logger, job, modules.JobFailed, nil, fmt.Sprintf("unknown job type %q", job.Type))
+		return
+	}
+
+	logger.Info("Job started", slog.Int("attempt", job.Attempts))
+
+	progress := func(done int, total int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cancelled, err := r.storage.UpdateJobProgress(job.JobId, done, total)
+		if err != nil {
+			logger.Error("Failed to record job progress", slog.String("error", err.Error()))
+			return nil
+		}
+		if cancelled {
+			return ErrCancelled
+		}
+		return nil
+	}
+
+	result, err := handler(ctx, job, progress)
+
+	switch {
+	case ctx.Err() != nil:
+		if err := r.storage.RequeueJob(job.JobId); err != nil {
+			logger.Error("Failed to requeue job", slog.String("error", err.Error()))
+			return
+		}
+		logger.Info("Job requeued for restart")
+	case errors.Is(err, ErrCancelled):
+		r.finish(logger, job, modules.JobCancelled, result, "")
+	case err != nil:
+		r.finish(logger, job, modules.JobFailed, result, err.Error())
+	default:
+		r.finish(logger, job, modules.JobSucceeded, result, "")
+	}
+}
+
+func (r *Runner) finish(logger *slog.Logger, job modules.Job, status string, result any, errMsg string) {
+	if err := r.storage.FinishJob(job.JobId, status, result, errMsg); err != nil {
+		logger.Error("Failed to finish job", slog.String("error", err.Error()))
+		return
+	}
+
+	logger.Info("Job finished", slog.String("status", status))
+}
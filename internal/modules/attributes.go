@@ -0,0 +1,88 @@
+package modules
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// AttributeDefinition describes one category-specific product attribute.
+type AttributeDefinition struct {
+	Type     string   `json:"type" validate:"required,oneof=string number boolean"`
+	Required bool     `json:"required,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// AttributeSchema maps attribute names to their definitions for a category.
+type AttributeSchema map[string]AttributeDefinition
+
+// Validate checks product attributes against the schema. Attributes that are
+// not declared in the schema are rejected.
+func (s AttributeSchema) Validate(attrs map[string]any) error {
+	var errMsgs []string
+
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def := s[name]
+		value, ok := attrs[name]
+		if !ok || value == nil {
+			if def.Required {
+				errMsgs = append(errMsgs, name+" is required")
+			}
+			continue
+		}
+
+		switch def.Type {
+		case "number":
+			n, ok := value.(float64)
+			if !ok {
+				errMsgs = append(errMsgs, name+" must be a number")
+				continue
+			}
+			if def.Min != nil && n < *def.Min {
+				errMsgs = append(errMsgs, fmt.Sprintf("%s must be at least %v", name, *def.Min))
+			}
+			if def.Max != nil && n > *def.Max {
+				errMsgs = append(errMsgs, fmt.Sprintf("%s must be at most %v", name, *def.Max))
+			}
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				errMsgs = append(errMsgs, name+" must be a boolean")
+			}
+		default:
+			str, ok := value.(string)
+			if !ok {
+				errMsgs = append(errMsgs, name+" must be a string")
+				continue
+			}
+			if len(def.Enum) > 0 && !slices.Contains(def.Enum, str) {
+				errMsgs = append(errMsgs, fmt.Sprintf("%s must be one of %s", name, strings.Join(def.Enum, ", ")))
+			}
+		}
+	}
+
+	var unknown []string
+	for name := range attrs {
+		if _, ok := s[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errMsgs = append(errMsgs, name+" is not defined for this category")
+	}
+
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errMsgs, ", "))
+	}
+
+	return nil
+}
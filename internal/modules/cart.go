@@ -11,9 +11,95 @@ type CartItem struct {
 	CartItemId  int       `json:"cart_item_id,omitempty" `
 	CartId      int       `json:"cart_id" validate:"required"`
 	ProductId   int       `json:"product_id" validate:"required"`
+	VariantId   *int      `json:"variant_id,omitempty"`
 	Quantity    int       `json:"quantity" validate:"required"`
-	PriceAtTime float64   `json:"price_at_time" validate:"required"`
-	Discount    float64   `json:"discount" validate:"required"`
-	Subtotal    float64   `json:"subtotal"`
+	PriceAtTime Money     `json:"price_at_time"`
+	Discount    Money     `json:"discount"`
+	Subtotal    Money     `json:"subtotal"`
 	AddedAt     time.Time `json:"added_at"`
 }
+
+// Cart warning codes.
+const (
+	CartWarningPriceChanged      = "price_changed"
+	CartWarningInsufficientStock = "insufficient_stock"
+	CartWarningUnavailable       = "unavailable"
+)
+
+// CartLine is an item in a CartSummary. UnitPrice is the price it was added
+// at and CurrentPrice what it would cost now; Total is LineTotal less
+// Discount.
+type CartLine struct {
+	CartItemId   int       `json:"cart_item_id"`
+	ProductId    int       `json:"product_id"`
+	VariantId    *int      `json:"variant_id,omitempty"`
+	Product      Product   `json:"product"`
+	Quantity     int       `json:"quantity"`
+	Available    int       `json:"available"`
+	UnitPrice    Money     `json:"unit_price"`
+	CurrentPrice *Money    `json:"current_price,omitempty"`
+	LineTotal    Money     `json:"line_total"`
+	Discount     Money     `json:"discount"`
+	Total        Money     `json:"total"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+// CartWarning flags a line the user should look at before checking out.
+type CartWarning struct {
+	CartItemId int    `json:"cart_item_id"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// CartSummary is a cart with its totals computed by the server. Savings is
+// the promotion discount plus what sale and price-list prices took off the
+// regular prices.
+type CartSummary struct {
+	CartId     int                `json:"cart_id,omitempty"`
+	Currency   string             `json:"currency"`
+	Lines      []CartLine         `json:"lines"`
+	Coupon     string             `json:"coupon,omitempty"`
+	Promotions []AppliedPromotion `json:"promotions"`
+	Subtotal   Money              `json:"subtotal"`
+	Discount   Money              `json:"discount"`
+	Tax        Money              `json:"tax"`
+	Shipping   Money              `json:"shipping"`
+	GrandTotal Money              `json:"grand_total"`
+	Savings    Money              `json:"savings"`
+	Warnings   []CartWarning      `json:"warnings"`
+}
+
+// Order is a cart once it has been placed, with the totals it was placed
+// at and the warehouses that ship it.
+type Order struct {
+	CartId int `json:"cart_id"`
+	CartTotals
+	Allocations []Allocation `json:"allocations"`
+}
+
+// CartOwner identifies whose cart is meant: a user's, or a guest's when
+// GuestId is set.
+type CartOwner struct {
+	UserId  int
+	GuestId string
+}
+
+// How MergeGuestCart resolves a line that is in both carts. Every strategy
+// caps the result at the stock available.
+const (
+	CartMergeSum   = "sum"   // add the quantities
+	CartMergeMax   = "max"   // keep the larger quantity
+	CartMergeUser  = "user"  // keep the user's quantity
+	CartMergeGuest = "guest" // take the guest's quantity
+)
+
+// StockReservation holds Quantity of a product or variant for a cart until
+// ExpiresAt, so other carts cannot claim the same units.
+type StockReservation struct {
+	ReservationId int       `json:"reservation_id"`
+	CartId        int       `json:"cart_id"`
+	ProductId     int       `json:"product_id"`
+	VariantId     *int      `json:"variant_id,omitempty"`
+	Quantity      int       `json:"quantity"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
@@ -0,0 +1,36 @@
+package modules
+
+import "time"
+
+// Change request statuses.
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+// ChangeRequest is a sensitive product edit held until a second admin
+// approves it. For an update, Fields lists the product columns the edit
+// writes and Proposed holds their new values. A restore proposes the whole
+// revision snapshot, a price action Proposed.Price as the explicit price in
+// its currency, a sale action the SalePrice in Sale, a variant action the
+// created or updated variant in Variant and a price_list action the created
+// or updated list in PriceList.
+type ChangeRequest struct {
+	ChangeRequestId int             `json:"change_request_id"`
+	ProductId       int             `json:"product_id"`
+	Action          string          `json:"action"`
+	BaseVersion     int             `json:"base_version"`
+	Fields          []string        `json:"fields,omitempty"`
+	Proposed        Product         `json:"proposed"`
+	Sale            *SalePrice      `json:"sale,omitempty"`
+	Variant         *ProductVariant `json:"variant,omitempty"`
+	PriceList       *PriceList      `json:"price_list,omitempty"`
+	Reason          string          `json:"reason"`
+	Status          string          `json:"status"`
+	RequestedBy     *int            `json:"requested_by,omitempty"`
+	ReviewedBy      *int            `json:"reviewed_by,omitempty"`
+	ReviewNote      string          `json:"review_note,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	ReviewedAt      *time.Time      `json:"reviewed_at,omitempty"`
+}
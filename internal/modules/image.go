@@ -0,0 +1,42 @@
+package modules
+
+import "encoding/json"
+
+// ImageRendition is a resized copy of a product image.
+type ImageRendition struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// ProductImage is a product image and its renditions, keyed "thumbnail",
+// "medium" and "large". Renditions are only known for uploaded images; a
+// rendition larger than the original points at the original.
+type ProductImage struct {
+	URL        string                    `json:"url" validate:"required"`
+	Width      int                       `json:"width,omitempty"`
+	Height     int                       `json:"height,omitempty"`
+	Renditions map[string]ImageRendition `json:"renditions,omitempty"`
+}
+
+// UnmarshalJSON also accepts a bare URL string, the format images had before
+// renditions, so older clients, revisions and caches still decode.
+func (i *ProductImage) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*i = ProductImage{URL: url}
+		return nil
+	}
+
+	type plain ProductImage
+	return json.Unmarshal(data, (*plain)(i))
+}
+
+// ImageURLs returns the original URL of each image.
+func ImageURLs(images []ProductImage) []string {
+	urls := make([]string, len(images))
+	for i, image := range images {
+		urls[i] = image.URL
+	}
+	return urls
+}
@@ -0,0 +1,61 @@
+package modules
+
+// Import row outcomes.
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+	ImportHeld      = "held"
+	ImportFailed    = "error"
+)
+
+// ImportRow is a product read from an import file. Fields lists the product
+// fields the file supplied; updating an existing product writes only those.
+type ImportRow struct {
+	Product Product
+	Fields  []string
+}
+
+// ImportResult reports what happened to one row of a bulk import.
+type ImportResult struct {
+	Line       int    `json:"line"`
+	SKU        string `json:"sku,omitempty"`
+	ExternalId string `json:"external_id,omitempty"`
+	ProductId  int    `json:"product_id,omitempty"`
+	Action     string `json:"action"`
+	Error      string `json:"error,omitempty"`
+
+	// ChangeRequestId is set when the row was held for approval.
+	ChangeRequestId int `json:"change_request_id,omitempty"`
+}
+
+// ImportReport summarises a bulk import. In a dry run nothing is written and
+// Created/Updated/Unchanged count what would have happened.
+type ImportReport struct {
+	DryRun    bool           `json:"dry_run"`
+	Total     int            `json:"total"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Held      int            `json:"held"`
+	Failed    int            `json:"failed"`
+	Rows      []ImportResult `json:"rows"`
+}
+
+// Add records a row result in the report totals.
+func (r *ImportReport) Add(result ImportResult) {
+	r.Total++
+	switch result.Action {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportUnchanged:
+		r.Unchanged++
+	case ImportHeld:
+		r.Held++
+	default:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
@@ -0,0 +1,42 @@
+package modules
+
+import "time"
+
+// Inventory movement types. Receipts, sales, adjustments and returns change
+// stock on hand and transfers move it between warehouses; reservations and
+// releases track what carts hold.
+const (
+	MovementReceipt     = "receipt"
+	MovementSale        = "sale"
+	MovementAdjustment  = "adjustment"
+	MovementReturn      = "return"
+	MovementTransfer    = "transfer"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+)
+
+// InventoryMovement is an entry in the stock ledger. Quantity is signed: a
+// sale of 3 is recorded as -3. Receipts, sales and returns are posted as
+// positive quantities; adjustments carry their own sign. WarehouseId is
+// where the stock moved, and is chosen for the poster when left out.
+type InventoryMovement struct {
+	MovementId  int       `json:"movement_id"`
+	ProductId   int       `json:"product_id"`
+	VariantId   *int      `json:"variant_id,omitempty"`
+	WarehouseId *int      `json:"warehouse_id,omitempty"`
+	Type        string    `json:"type" validate:"required,oneof=receipt sale adjustment return"`
+	Quantity    int       `json:"quantity" validate:"required"`
+	Reason      string    `json:"reason" validate:"required"`
+	ActorId     *int      `json:"actor_id,omitempty"`
+	CartId      *int      `json:"cart_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StockDiscrepancy is a product or variant whose stock differs from the sum
+// of its ledger.
+type StockDiscrepancy struct {
+	ProductId int  `json:"product_id"`
+	VariantId *int `json:"variant_id,omitempty"`
+	Stock     int  `json:"stock"`
+	Ledger    int  `json:"ledger"`
+}
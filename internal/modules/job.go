@@ -0,0 +1,50 @@
+package modules
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job types.
+const (
+	JobImport    = "import"
+	JobBulkPrice = "bulk_price"
+	JobReindex   = "reindex"
+)
+
+// Job is a background operation too long for one HTTP request. Progress and
+// Total are in units the job type chooses (rows, products, steps); Total is 0
+// while unknown.
+type Job struct {
+	JobId           int             `json:"job_id"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	Params          json.RawMessage `json:"params,omitempty"`
+	Progress        int             `json:"progress"`
+	Total           int             `json:"total"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancel_requested"`
+	Attempts        int             `json:"attempts"`
+	CreatedBy       *int            `json:"created_by,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+}
+
+// PriceTarget is one product's price change in a bulk price job.
+// From and To are amounts in minor units of the product's currency.
+type PriceTarget struct {
+	ProductId int   `json:"product_id"`
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+}
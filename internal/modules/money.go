@@ -0,0 +1,71 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an amount in a currency's minor units (paise, cents) with its
+// ISO 4217 code. An empty Currency means the catalog's default currency.
+type Money struct {
+	Amount   int64  `json:"amount" validate:"gte=0"`
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+}
+
+// minorDigits lists currencies that do not have two decimal places.
+var minorDigits = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorDigits returns the number of decimal places of currency.
+func MinorDigits(currency string) int {
+	if digits, ok := minorDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// MinorUnitFactor returns how many minor units make one unit of currency.
+func MinorUnitFactor(currency string) int64 {
+	return int64(math.Pow10(MinorDigits(currency)))
+}
+
+// Times returns m multiplied by n.
+func (m Money) Times(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Decimal formats the amount in major units, e.g. "1200.50".
+func (m Money) Decimal() string {
+	digits := MinorDigits(m.Currency)
+	if digits == 0 {
+		return fmt.Sprint(m.Amount)
+	}
+
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	factor := MinorUnitFactor(m.Currency)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/factor, digits, amount%factor)
+}
+
+// String formats m as "1200.50 INR".
+func (m Money) String() string {
+	return strings.TrimSpace(m.Decimal() + " " + m.Currency)
+}
+
+// UnmarshalJSON rejects a bare number: whether it meant whole or minor units,
+// and in which currency, is ambiguous, so prices must be sent as objects.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var units float64
+	if err := json.Unmarshal(data, &units); err == nil {
+		return fmt.Errorf("price must be an object with an amount in minor units and a currency, not a bare number")
+	}
+
+	type plain Money
+	return json.Unmarshal(data, (*plain)(m))
+}
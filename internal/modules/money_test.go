@@ -0,0 +1,89 @@
+package modules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		digits   int
+		factor   int64
+	}{
+		{"INR", 2, 100},
+		{"USD", 2, 100},
+		{"", 2, 100},
+		{"JPY", 0, 1},
+		{"KRW", 0, 1},
+		{"KWD", 3, 1000},
+		{"BHD", 3, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			if got := MinorDigits(tt.currency); got != tt.digits {
+				t.Errorf("MinorDigits = %d, want %d", got, tt.digits)
+			}
+			if got := MinorUnitFactor(tt.currency); got != tt.factor {
+				t.Errorf("MinorUnitFactor = %d, want %d", got, tt.factor)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		money   Money
+		decimal string
+		str     string
+	}{
+		{Money{Amount: 120050, Currency: "INR"}, "1200.50", "1200.50 INR"},
+		{Money{Amount: 5, Currency: "USD"}, "0.05", "0.05 USD"},
+		{Money{Amount: -1999, Currency: "EUR"}, "-19.99", "-19.99 EUR"},
+		{Money{Amount: 1500, Currency: "JPY"}, "1500", "1500 JPY"},
+		{Money{Amount: 1250, Currency: "KWD"}, "1.250", "1.250 KWD"},
+		{Money{Amount: 7, Currency: "BHD"}, "0.007", "0.007 BHD"},
+		{Money{Amount: 100}, "1.00", "1.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			if got := tt.money.Decimal(); got != tt.decimal {
+				t.Errorf("Decimal = %q, want %q", got, tt.decimal)
+			}
+			if got := tt.money.String(); got != tt.str {
+				t.Errorf("String = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{name: "object", input: `{"amount":120050,"currency":"INR"}`, want: Money{Amount: 120050, Currency: "INR"}},
+		{name: "zero-decimal currency", input: `{"amount":1500,"currency":"JPY"}`, want: Money{Amount: 1500, Currency: "JPY"}},
+		{name: "default currency", input: `{"amount":999}`, want: Money{Amount: 999}},
+		{name: "bare integer", input: `1200`, wantErr: true},
+		{name: "bare decimal", input: `12.50`, wantErr: true},
+		{name: "string", input: `"12.50"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
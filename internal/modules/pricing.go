@@ -0,0 +1,60 @@
+package modules
+
+import "time"
+
+// PriceContext describes who a price is being shown to. An empty Currency
+// means prices are returned in each product's own currency; UserId, when
+// set, brings in the price lists of the user's groups.
+type PriceContext struct {
+	Currency string
+	UserId   int
+}
+
+// ExchangeRate converts From into To: one unit of From is Rate units of To.
+type ExchangeRate struct {
+	From      string    `json:"from" validate:"required,iso4217"`
+	To        string    `json:"to" validate:"required,iso4217,nefield=From"`
+	Rate      float64   `json:"rate" validate:"gt=0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserGroup is a set of users, such as a B2B account, that price lists are
+// assigned to.
+type UserGroup struct {
+	GroupId int    `json:"group_id,omitempty"`
+	Name    string `json:"name" validate:"required"`
+	Members []int  `json:"members"`
+}
+
+// PriceList holds negotiated prices for the members of one user group. It
+// applies between StartsAt and EndsAt; either may be open-ended.
+type PriceList struct {
+	PriceListId int             `json:"price_list_id,omitempty"`
+	Name        string          `json:"name" validate:"required"`
+	GroupId     int             `json:"group_id" validate:"required"`
+	Currency    string          `json:"currency" validate:"required,iso4217"`
+	StartsAt    *time.Time      `json:"starts_at,omitempty"`
+	EndsAt      *time.Time      `json:"ends_at,omitempty"`
+	Items       []PriceListItem `json:"items" validate:"dive"`
+}
+
+type PriceListItem struct {
+	ProductId int   `json:"product_id" validate:"required"`
+	Amount    int64 `json:"amount" validate:"gte=0"`
+}
+
+// SalePrice is a time-boxed price for a product, shown while it is lower
+// than the regular price.
+type SalePrice struct {
+	SaleId    int        `json:"sale_id,omitempty"`
+	ProductId int        `json:"product_id,omitempty"`
+	Price     Money      `json:"price"`
+	StartsAt  time.Time  `json:"starts_at" validate:"required"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+}
+
+// PriceChange is one entry of a product's price history.
+type PriceChange struct {
+	Price     Money     `json:"price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -1,13 +1,37 @@
 package modules
 
+import "time"
 
+// Product lifecycle statuses. Only published products are visible publicly.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Product.RegularPrice and LowestPrice30d are only set on reads.
+// RegularPrice is shown when a sale or price list brings Price below the
+// regular price; LowestPrice30d is the lowest price, regular or offered, in
+// force at any point in the 30 days before that reduction started, or before
+// now when there is none.
 type Product struct {
-    ProductId        int   `json:"product_id,omitempty" `        
-    Name       string   `json:"name" validate:"required"`                
-    Price      int      `json:"price" validate:"required"`               
-    Stock      int      `json:"stock" validate:"required"`              
-    CategoryID string   `json:"category_id" validate:"required"`  
-    Quantity    int     `json:"quantity" validate:"required"`       
-    Brand      string   `json:"brand" validate:"required"`               
-    Images     []string `json:"images,omitempty"`    
+	ProductId      int              `json:"product_id,omitempty" `
+	SKU            string           `json:"sku,omitempty"`
+	ExternalId     string           `json:"external_id,omitempty"`
+	Name           string           `json:"name" validate:"required"`
+	Price          Money            `json:"price"`
+	RegularPrice   *Money           `json:"regular_price,omitempty"`
+	LowestPrice30d *Money           `json:"lowest_price_30d,omitempty"`
+	Stock          int              `json:"stock" validate:"required"`
+	CategoryID     string           `json:"category_id" validate:"required"`
+	Quantity       int              `json:"quantity" validate:"required"`
+	Brand          string           `json:"brand" validate:"required"`
+	Images         []ProductImage   `json:"images,omitempty" validate:"dive"`
+	Attributes     map[string]any   `json:"attributes,omitempty"`
+	Variants       []ProductVariant `json:"variants,omitempty"`
+	Version        int              `json:"version,omitempty"`
+	DeletedAt      *time.Time       `json:"deleted_at,omitempty"`
+	Status         string           `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt      *time.Time       `json:"publish_at,omitempty" validate:"required_if=Status scheduled"`
 }
@@ -0,0 +1,48 @@
+package modules
+
+import "time"
+
+// Promotion types.
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+	PromotionBuyXGetY   = "buy_x_get_y"
+)
+
+// Promotion is a cart discount. Promotions with a Code apply once the code
+// is entered on the cart; the rest apply automatically. ProductId limits it
+// to one product's lines, and PerUserLimit to that many placed orders per
+// user or guest.
+type Promotion struct {
+	PromotionId  int        `json:"promotion_id,omitempty"`
+	Name         string     `json:"name" validate:"required"`
+	Code         string     `json:"code,omitempty" validate:"omitempty,max=64"`
+	Type         string     `json:"type" validate:"required,oneof=percentage fixed buy_x_get_y"`
+	Percent      int        `json:"percent,omitempty" validate:"required_if=Type percentage,omitempty,min=1,max=100"`
+	Amount       *Money     `json:"amount,omitempty" validate:"required_if=Type fixed"`
+	MinSpend     *Money     `json:"min_spend,omitempty"`
+	ProductId    int        `json:"product_id,omitempty"`
+	BuyQuantity  int        `json:"buy_quantity,omitempty" validate:"required_if=Type buy_x_get_y,omitempty,min=1"`
+	GetQuantity  int        `json:"get_quantity,omitempty" validate:"required_if=Type buy_x_get_y,omitempty,min=1"`
+	PerUserLimit int        `json:"per_user_limit,omitempty" validate:"gte=0"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Disabled     bool       `json:"disabled,omitempty"`
+}
+
+// AppliedPromotion is a promotion that took Discount off a cart.
+type AppliedPromotion struct {
+	PromotionId int    `json:"promotion_id"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Discount    Money  `json:"discount"`
+}
+
+// CartTotals are the totals of a cart after promotions.
+type CartTotals struct {
+	Subtotal   Money              `json:"subtotal"`
+	Discount   Money              `json:"discount"`
+	Total      Money              `json:"total"`
+	Coupon     string             `json:"coupon,omitempty"`
+	Promotions []AppliedPromotion `json:"promotions"`
+}
@@ -0,0 +1,22 @@
+package modules
+
+import "time"
+
+// FieldChange is one field's value before and after a product change.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// ProductRevision records one create, update, delete or restore of a product.
+// Snapshot holds the product as it stood after the change, or just before it
+// for deletes, and is what a restore writes back.
+type ProductRevision struct {
+	RevisionId int                    `json:"revision_id"`
+	ProductId  int                    `json:"product_id"`
+	Action     string                 `json:"action"`
+	ActorId    *int                   `json:"actor_id,omitempty"`
+	ChangedAt  time.Time              `json:"changed_at"`
+	Diff       map[string]FieldChange `json:"diff"`
+	Snapshot   Product                `json:"snapshot"`
+}
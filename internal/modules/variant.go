@@ -0,0 +1,13 @@
+package modules
+
+// ProductVariant is a sellable SKU of a parent product, e.g. one size/colour
+// combination of a shirt. Price overrides the parent price when set.
+type ProductVariant struct {
+	VariantId int               `json:"variant_id,omitempty"`
+	ProductId int               `json:"product_id,omitempty"`
+	SKU       string            `json:"sku" validate:"required"`
+	Options   map[string]string `json:"options" validate:"required"`
+	Price     *Money            `json:"price,omitempty" validate:"omitempty"`
+	Stock     int               `json:"stock" validate:"min=0"`
+	Images    []ProductImage    `json:"images,omitempty" validate:"dive"`
+}
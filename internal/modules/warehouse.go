@@ -0,0 +1,65 @@
+package modules
+
+import "time"
+
+// Allocation strategies.
+const (
+	AllocateNearest   = "nearest"
+	AllocateMostStock = "most_stock"
+)
+
+// Location is a point on the map, in degrees.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Warehouse is a location stock is held and shipped from. Without a
+// location it is never the nearest.
+type Warehouse struct {
+	WarehouseId int        `json:"warehouse_id,omitempty"`
+	Code        string     `json:"code" validate:"required,max=32"`
+	Name        string     `json:"name" validate:"required"`
+	Latitude    *float64   `json:"latitude,omitempty" validate:"omitempty,gte=-90,lte=90,required_with=Longitude"`
+	Longitude   *float64   `json:"longitude,omitempty" validate:"omitempty,gte=-180,lte=180,required_with=Latitude"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// WarehouseStock is how much of a product or variant one warehouse holds.
+type WarehouseStock struct {
+	WarehouseId int    `json:"warehouse_id"`
+	Code        string `json:"code"`
+	ProductId   int    `json:"product_id"`
+	VariantId   *int   `json:"variant_id,omitempty"`
+	Quantity    int    `json:"quantity"`
+}
+
+// StockTransfer moves Quantity of a product or variant between warehouses.
+type StockTransfer struct {
+	ProductId       int    `json:"product_id" validate:"required"`
+	VariantId       *int   `json:"variant_id,omitempty"`
+	FromWarehouseId int    `json:"from_warehouse_id" validate:"required"`
+	ToWarehouseId   int    `json:"to_warehouse_id" validate:"required,nefield=FromWarehouseId"`
+	Quantity        int    `json:"quantity" validate:"gt=0"`
+	Reason          string `json:"reason"`
+}
+
+// Allocation assigns Quantity of a cart line to a warehouse.
+type Allocation struct {
+	CartItemId  int `json:"cart_item_id"`
+	WarehouseId int `json:"warehouse_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// Shortfall is the part of a cart line no warehouse can fill.
+type Shortfall struct {
+	CartItemId int `json:"cart_item_id"`
+	Quantity   int `json:"quantity"`
+}
+
+// CartAllocation is the plan for shipping a cart from warehouses.
+type CartAllocation struct {
+	Strategy    string       `json:"strategy"`
+	Allocations []Allocation `json:"allocations"`
+	Shortfalls  []Shortfall  `json:"shortfalls"`
+}
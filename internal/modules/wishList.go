@@ -4,5 +4,6 @@ type WishList struct {
 	WishListId int    `json:"wishList_id,omitempty" `
 	ProductId  int    `json:"product_id,omitempty" validate:"required"`
 	UserId     int    `json:"user_id,omitempty" validate:"required"`
+	VariantId  *int   `json:"variant_id,omitempty"`
 	AddedAt    string `json:"added_at"`
 }
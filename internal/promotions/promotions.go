@@ -0,0 +1,162 @@
+// Package promotions works out cart discounts from a set of promotions.
+package promotions
+
+import (
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// Line is a cart line as the engine sees it.
+type Line struct {
+	Id        int
+	ProductId int
+	Quantity  int
+	UnitPrice modules.Money
+}
+
+// Result is the outcome of Apply. Discounts holds the total discount of each
+// line by line id. Skipped explains, by promotion id, why a promotion took
+// nothing off the cart.
+type Result struct {
+	Discounts map[int]int64
+	Applied   []modules.AppliedPromotion
+	Skipped   map[int]string
+}
+
+// Redeemable returns the promotions a customer may still use, dropping
+// those whose PerUserLimit the customer's redemptions, counted in used by
+// promotion id, have reached.
+func Redeemable(promotions []modules.Promotion, used map[int]int) []modules.Promotion {
+	var redeemable []modules.Promotion
+	for _, promo := range promotions {
+		if promo.PerUserLimit > 0 && used[promo.PromotionId] >= promo.PerUserLimit {
+			continue
+		}
+		redeemable = append(redeemable, promo)
+	}
+	return redeemable
+}
+
+// Apply runs promotions over lines in order. Each promotion discounts what
+// earlier ones left, so a line is never discounted below zero.
+func Apply(lines []Line, promotions []modules.Promotion) Result {
+	result := Result{Discounts: map[int]int64{}, Skipped: map[int]string{}}
+
+	for _, promo := range promotions {
+		discounts, reason := discount(promo, lines, result.Discounts)
+		if reason != "" {
+			result.Skipped[promo.PromotionId] = reason
+			continue
+		}
+
+		var total int64
+		var currency string
+		for _, line := range lines {
+			if d := discounts[line.Id]; d > 0 {
+				result.Discounts[line.Id] += d
+				total += d
+				currency = line.UnitPrice.Currency
+			}
+		}
+
+		result.Applied = append(result.Applied, modules.AppliedPromotion{
+			PromotionId: promo.PromotionId,
+			Name:        promo.Name,
+			Code:        promo.Code,
+			Discount:    modules.Money{Amount: total, Currency: currency},
+		})
+	}
+
+	return result
+}
+
+// discount returns what promo takes off each line, or why it does not apply.
+func discount(promo modules.Promotion, lines []Line, taken map[int]int64) (map[int]int64, string) {
+	var eligible []Line
+	var spend int64
+	for _, line := range lines {
+		if promo.ProductId != 0 && line.ProductId != promo.ProductId {
+			continue
+		}
+		if currency := promoCurrency(promo); currency != "" && line.UnitPrice.Currency != currency {
+			continue
+		}
+		eligible = append(eligible, line)
+		spend += line.UnitPrice.Amount * int64(line.Quantity)
+	}
+
+	if len(eligible) == 0 {
+		return nil, "no items in the cart qualify"
+	}
+	if promo.MinSpend != nil && spend < promo.MinSpend.Amount {
+		return nil, fmt.Sprintf("spend at least %s on qualifying items", promo.MinSpend)
+	}
+
+	remaining := func(line Line) int64 {
+		return line.UnitPrice.Amount*int64(line.Quantity) - taken[line.Id]
+	}
+
+	discounts := map[int]int64{}
+	switch promo.Type {
+	case modules.PromotionPercentage:
+		for _, line := range eligible {
+			discounts[line.Id] = (remaining(line)*int64(promo.Percent) + 50) / 100
+		}
+
+	case modules.PromotionFixed:
+		var total int64
+		for _, line := range eligible {
+			total += remaining(line)
+		}
+		off := min(promo.Amount.Amount, total)
+
+		// Spread the amount over the lines in proportion to what is left
+		// on each, giving the rounding remainder to the last line.
+		var given int64
+		for i, line := range eligible {
+			d := off - given
+			if i < len(eligible)-1 && total > 0 {
+				d = off * remaining(line) / total
+			}
+			discounts[line.Id] = d
+			given += d
+		}
+
+	case modules.PromotionBuyXGetY:
+		freeUnits := 0
+		for _, line := range eligible {
+			free := line.Quantity / (promo.BuyQuantity + promo.GetQuantity) * promo.GetQuantity
+			discounts[line.Id] = min(int64(free)*line.UnitPrice.Amount, remaining(line))
+			freeUnits += free
+		}
+		if freeUnits == 0 {
+			return nil, fmt.Sprintf("add %d of an item to get %d free", promo.BuyQuantity+promo.GetQuantity, promo.GetQuantity)
+		}
+	}
+
+	if sum(discounts) == 0 {
+		return nil, "nothing left to discount"
+	}
+	return discounts, ""
+}
+
+// promoCurrency returns the currency promo's amounts are in, if any. Lines
+// in other currencies do not qualify.
+func promoCurrency(promo modules.Promotion) string {
+	if promo.Amount != nil {
+		return promo.Amount.Currency
+	}
+	if promo.MinSpend != nil {
+		return promo.MinSpend.Currency
+	}
+	return ""
+}
+
+func sum(discounts map[int]int64) int64 {
+	var total int64
+	for _, d := range discounts {
+		total += d
+	}
+	return total
+}
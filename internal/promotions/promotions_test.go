@@ -0,0 +1,156 @@
+package promotions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+func TestApply(t *testing.T) {
+	eur := func(amount int64) *modules.Money { return &modules.Money{Amount: amount, Currency: "EUR"} }
+	lines := []Line{
+		{Id: 1, ProductId: 1, Quantity: 2, UnitPrice: *eur(1000)},
+		{Id: 2, ProductId: 2, Quantity: 1, UnitPrice: *eur(1000)},
+	}
+
+	tests := []struct {
+		name          string
+		promos        []modules.Promotion
+		wantDiscounts map[int]int64
+		wantSkipped   map[int]string
+	}{
+		{
+			name:          "percentage",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionPercentage, Percent: 10}},
+			wantDiscounts: map[int]int64{1: 200, 2: 100},
+		},
+		{
+			name:          "fixed amount is spread over the lines",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionFixed, Amount: eur(300)}},
+			wantDiscounts: map[int]int64{1: 200, 2: 100},
+		},
+		{
+			name:          "fixed amount above the cart total",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionFixed, Amount: eur(5000)}},
+			wantDiscounts: map[int]int64{1: 2000, 2: 1000},
+		},
+		{
+			name:          "buy one get one on a product",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionBuyXGetY, ProductId: 1, BuyQuantity: 1, GetQuantity: 1}},
+			wantDiscounts: map[int]int64{1: 1000},
+		},
+		{
+			name:          "too few units for buy x get y",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+			wantDiscounts: map[int]int64{},
+			wantSkipped:   map[int]string{1: "add 3 of an item to get 1 free"},
+		},
+		{
+			name:          "minimum spend not met",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionPercentage, Percent: 10, MinSpend: eur(5000)}},
+			wantDiscounts: map[int]int64{},
+			wantSkipped:   map[int]string{1: "spend at least 50.00 EUR on qualifying items"},
+		},
+		{
+			name:          "limited to one product",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionPercentage, Percent: 10, ProductId: 2}},
+			wantDiscounts: map[int]int64{2: 100},
+		},
+		{
+			name:          "amount in another currency",
+			promos:        []modules.Promotion{{PromotionId: 1, Type: modules.PromotionFixed, Amount: &modules.Money{Amount: 100, Currency: "USD"}}},
+			wantDiscounts: map[int]int64{},
+			wantSkipped:   map[int]string{1: "no items in the cart qualify"},
+		},
+		{
+			name: "later promotions discount what earlier ones left",
+			promos: []modules.Promotion{
+				{PromotionId: 1, Type: modules.PromotionPercentage, Percent: 50},
+				{PromotionId: 2, Type: modules.PromotionFixed, Amount: eur(2000)},
+			},
+			wantDiscounts: map[int]int64{1: 2000, 2: 1000},
+		},
+		{
+			name: "nothing left to discount",
+			promos: []modules.Promotion{
+				{PromotionId: 1, Type: modules.PromotionPercentage, Percent: 100},
+				{PromotionId: 2, Type: modules.PromotionPercentage, Percent: 10},
+			},
+			wantDiscounts: map[int]int64{1: 2000, 2: 1000},
+			wantSkipped:   map[int]string{2: "nothing left to discount"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(lines, tt.promos)
+			if !reflect.DeepEqual(got.Discounts, tt.wantDiscounts) {
+				t.Errorf("discounts = %v, want %v", got.Discounts, tt.wantDiscounts)
+			}
+			if tt.wantSkipped == nil {
+				tt.wantSkipped = map[int]string{}
+			}
+			if !reflect.DeepEqual(got.Skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", got.Skipped, tt.wantSkipped)
+			}
+
+			var applied int64
+			for _, promo := range got.Applied {
+				applied += promo.Discount.Amount
+			}
+			if total := sum(got.Discounts); applied != total {
+				t.Errorf("applied promotions total %d, line discounts %d", applied, total)
+			}
+		})
+	}
+}
+
+func TestRedeemable(t *testing.T) {
+	promos := []modules.Promotion{
+		{PromotionId: 1, Name: "unlimited"},
+		{PromotionId: 2, Name: "once", PerUserLimit: 1},
+		{PromotionId: 3, Name: "twice", PerUserLimit: 2},
+	}
+
+	tests := []struct {
+		name string
+		used map[int]int
+		want []int
+	}{
+		{name: "nothing redeemed", used: map[int]int{}, want: []int{1, 2, 3}},
+		{name: "limit reached", used: map[int]int{2: 1}, want: []int{1, 3}},
+		{name: "under the limit", used: map[int]int{3: 1}, want: []int{1, 2, 3}},
+		{name: "over the limit", used: map[int]int{2: 3, 3: 2}, want: []int{1}},
+		{name: "no limit", used: map[int]int{1: 100}, want: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Redeemable(promos, tt.used)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d promotions, want %v", len(got), tt.want)
+			}
+			for i, promo := range got {
+				if promo.PromotionId != tt.want[i] {
+					t.Errorf("promotion %d = %d, want %d", i, promo.PromotionId, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplySkipsUsedUpCoupon(t *testing.T) {
+	coupon := modules.Promotion{PromotionId: 7, Name: "welcome", Code: "WELCOME", Type: modules.PromotionPercentage, Percent: 10, PerUserLimit: 1}
+	lines := []Line{{Id: 1, ProductId: 1, Quantity: 1, UnitPrice: modules.Money{Amount: 1000, Currency: "EUR"}}}
+
+	first := Apply(lines, Redeemable([]modules.Promotion{coupon}, map[int]int{}))
+	if len(first.Applied) != 1 || first.Discounts[1] != 100 {
+		t.Fatalf("first order: applied %v, discounts %v", first.Applied, first.Discounts)
+	}
+
+	second := Apply(lines, Redeemable([]modules.Promotion{coupon}, map[int]int{7: 1}))
+	if len(second.Applied) != 0 || second.Discounts[1] != 0 {
+		t.Errorf("second order: applied %v, discounts %v", second.Applied, second.Discounts)
+	}
+}
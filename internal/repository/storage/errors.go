@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// Errors returned by Storage implementations that handlers map to 4xx
+// responses. Implementations wrap them with details using %w.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrInvalidAttributes   = errors.New("invalid attributes")
+	ErrInvalidFilter       = errors.New("invalid filter")
+	ErrVersionMismatch     = errors.New("version mismatch")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrConflict            = errors.New("conflict")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrVariantRequired     = errors.New("variant required")
+)
+
+// HeldForApproval is returned instead of applying a change that needs a
+// second admin's sign-off. The change has been saved as ChangeRequest and is
+// applied when it is approved.
+type HeldForApproval struct {
+	ChangeRequest modules.ChangeRequest
+}
+
+func (e *HeldForApproval) Error() string {
+	return "change requires approval by another admin: " + e.ChangeRequest.Reason
+}
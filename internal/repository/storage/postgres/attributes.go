@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+var attributeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func (p *Postgres) SetAttributeSchema(categoryId int, schema modules.AttributeSchema) error {
+	for name := range schema {
+		if !attributeNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: attribute name %q may only contain letters, digits and underscores", storage.ErrInvalidAttributes, name)
+		}
+	}
+
+	_, err := p.Db.Exec(`
+		INSERT INTO category_attribute_schemas (category_id, schema, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (category_id) DO UPDATE SET schema = EXCLUDED.schema, updated_at = EXCLUDED.updated_at
+	`, categoryId, jsonb{schema})
+	if err != nil {
+		return fmt.Errorf("error saving attribute schema: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetAttributeSchema(categoryId int) (modules.AttributeSchema, error) {
+	schema := modules.AttributeSchema{}
+
+	err := p.Db.QueryRow(`SELECT schema FROM category_attribute_schemas WHERE category_id = $1`, categoryId).Scan(jsonb{&schema})
+	if err == sql.ErrNoRows {
+		return schema, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attribute schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// validateAttributes checks attributes against the category's schema.
+// Categories without a schema accept free-form attributes.
+func (p *Postgres) validateAttributes(categoryId string, attributes map[string]any) error {
+	var schema modules.AttributeSchema
+
+	err := p.Db.QueryRow(`SELECT schema FROM category_attribute_schemas WHERE category_id = $1`, categoryId).Scan(jsonb{&schema})
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching attribute schema: %w", err)
+	}
+
+	if err := schema.Validate(attributes); err != nil {
+		return fmt.Errorf("%w: %s", storage.ErrInvalidAttributes, err)
+	}
+
+	return nil
+}
+
+func nonNilAttributes(attributes map[string]any) map[string]any {
+	if attributes == nil {
+		return map[string]any{}
+	}
+	return attributes
+}
+
+// parseAttributeFilter splits a query parameter such as attr.ram_gb>=16
+// into the attribute name, operator and value. url.ParseQuery splits on the
+// first '=', so "attr.ram_gb>=16" arrives as key "attr.ram_gb>" with value
+// "16", and "attr.ram_gb>16" as key "attr.ram_gb>16" with an empty value.
+func parseAttributeFilter(key string, value string) (string, string, string, error) {
+	name := strings.TrimPrefix(key, "attr.")
+	op := "="
+
+	switch {
+	case value == "":
+		i := strings.IndexAny(name, "<>")
+		if i < 0 {
+			return "", "", "", fmt.Errorf("%w: %s has no value", storage.ErrInvalidFilter, key)
+		}
+		op = name[i : i+1]
+		name, value = name[:i], name[i+1:]
+	case strings.HasSuffix(name, ">"), strings.HasSuffix(name, "<"), strings.HasSuffix(name, "!"):
+		op = name[len(name)-1:] + "="
+		name = name[:len(name)-1]
+	}
+
+	if !attributeNamePattern.MatchString(name) {
+		return "", "", "", fmt.Errorf("%w: invalid attribute name %q", storage.ErrInvalidFilter, name)
+	}
+
+	return name, op, value, nil
+}
+
+// attributeTypes returns, by attribute name, the types category schemas
+// declare for the attributes filtered on: the filtered category's schema
+// when category_id is set, every schema otherwise.
+func (p *Postgres) attributeTypes(filters map[string][]string) (map[string][]string, error) {
+	var names []string
+	for k, v := range filters {
+		if !strings.HasPrefix(k, "attr.") {
+			continue
+		}
+		if name, _, _, err := parseAttributeFilter(k, v[0]); err == nil {
+			names = append(names, name)
+		}
+	}
+
+	types := map[string][]string{}
+	if len(names) == 0 {
+		return types, nil
+	}
+
+	category := ""
+	if c, ok := filters["category_id"]; ok {
+		category = c[0]
+	}
+
+	rows, err := p.Db.Query(`
+		SELECT DISTINCT a.key, a.value->>'type'
+		FROM category_attribute_schemas c, jsonb_each(c.schema) a
+		WHERE a.key = ANY($1) AND ($2 = '' OR c.category_id::TEXT = $2)
+		ORDER BY 1, 2
+	`, pq.Array(names), category)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attribute types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, attrType string
+		if err := rows.Scan(&name, &attrType); err != nil {
+			return nil, err
+		}
+		types[name] = append(types[name], attrType)
+	}
+
+	return types, rows.Err()
+}
+
+// attributeFilter turns a parsed attribute filter into a WHERE clause and its
+// arguments, numbering placeholders from argID. Equality encodes the value
+// as each of the attribute's declared types it parses as, or as a string,
+// number or boolean when no schema declares the attribute, and matches any of
+// them with JSONB containment. Ranges use a jsonpath predicate. Both are
+// served by the GIN index on attributes.
+func attributeFilter(name string, op string, value string, types []string, argID int) (string, []any, error) {
+	if op == "=" || op == "!=" {
+		var candidates []any
+		if len(types) == 0 {
+			candidates = append(candidates, value)
+			types = []string{"number", "boolean"}
+		}
+		for _, t := range types {
+			switch t {
+			case "number":
+				if n, err := strconv.ParseFloat(value, 64); err == nil {
+					candidates = append(candidates, n)
+				}
+			case "boolean":
+				if b, err := strconv.ParseBool(value); err == nil {
+					candidates = append(candidates, b)
+				}
+			default:
+				candidates = append(candidates, value)
+			}
+		}
+		if len(candidates) == 0 {
+			return "", nil, fmt.Errorf("%w: %s must be a %s", storage.ErrInvalidFilter, name, strings.Join(types, " or "))
+		}
+
+		var matches []string
+		var args []any
+		for _, v := range candidates {
+			data, _ := json.Marshal(map[string]any{name: v})
+			matches = append(matches, fmt.Sprintf("p.attributes @> $%d::jsonb", argID+len(args)))
+			args = append(args, string(data))
+		}
+
+		clause := "AND (" + strings.Join(matches, " OR ") + ") "
+		if op == "!=" {
+			clause = "AND NOT (" + strings.Join(matches, " OR ") + ") "
+		}
+		return clause, args, nil
+	}
+
+	if len(types) > 0 && !slices.Contains(types, "number") {
+		return "", nil, fmt.Errorf("%w: %s is not a number attribute", storage.ErrInvalidFilter, name)
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s%s needs a numeric value", storage.ErrInvalidFilter, name, op)
+	}
+
+	path := fmt.Sprintf(`$.%q %s %s`, name, op, strconv.FormatFloat(n, 'f', -1, 64))
+	return fmt.Sprintf("AND p.attributes @@ $%d::jsonpath ", argID), []any{path}, nil
+}
@@ -2,80 +2,83 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
-	"github.com/lib/pq"
 	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
 )
 
-func (p *Postgres) AddToCart(user_id int, product_id int, quantity int, discount int) (int, error) {
+// AddToCart adds quantity of a product or variant to the owner's active cart,
+// snapshotting the effective price for pc, sales and price lists included,
+// reserves the line's stock and recomputes the cart's promotions. A product
+// with variants is added by variant; without one it fails with
+// ErrVariantRequired.
+func (p *Postgres) AddToCart(owner modules.CartOwner, product_id int, variant_id int, quantity int, pc modules.PriceContext) (int, error) {
+	if variant_id == 0 {
+		var hasVariants bool
+		err := p.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1 AND deleted_at IS NULL)`, product_id).Scan(&hasVariants)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check variants: %w", err)
+		}
+		if hasVariants {
+			return 0, fmt.Errorf("%w: product %d is sold by variant, give a variant_id", storage.ErrVariantRequired, product_id)
+		}
+	}
 
-	var cartID int
+	price, _, err := p.variantPriceAndStock(product_id, variant_id, pc)
+	if err != nil {
+		return 0, err
+	}
 
-	err := p.Db.QueryRow(`
-		SELECT cart_id FROM cartTable WHERE user_id = $1 AND status = 'active' LIMIT 1
-	`, user_id).Scan(&cartID)
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	if err == sql.ErrNoRows {
-		err = p.Db.QueryRow(`
-			INSERT INTO cartTable (user_id, status) VALUES ($1, 'active') RETURNING cart_id
-		`, user_id).Scan(&cartID)
+	cartID, err := activeCartId(tx, owner)
+	if errors.Is(err, storage.ErrNotFound) {
+		// A concurrent add may create the cart first; then this one uses it.
+		column, value := cartOwnerColumn(owner)
+		_, err = tx.Exec(`INSERT INTO cartTable (`+column+`, status) VALUES ($1, 'active') ON CONFLICT DO NOTHING`, value)
 		if err != nil {
 			return 0, fmt.Errorf("failed to create cart: %w", err)
 		}
-	} else if err != nil {
-		return 0, fmt.Errorf("failed to fetch cart: %w", err)
+		cartID, err = activeCartId(tx, owner)
 	}
-
-	var price float64
-	var stock int
-
-	err = p.Db.QueryRow(`
-		SELECT price, stock FROM products WHERE product_id = $1
-	`, product_id).Scan(&price, &stock)
-
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch product details: %w", err)
+		return 0, err
 	}
 
-	if quantity > stock {
-		return 0, fmt.Errorf("cannot add %d items, only %d available in stock", quantity, stock)
+	var cartCurrency sql.NullString
+	err = tx.QueryRow(`SELECT MIN(currency) FROM cartItems WHERE cart_id = $1`, cartID).Scan(&cartCurrency)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check cart currency: %w", err)
+	}
+	if cartCurrency.Valid && cartCurrency.String != price.Currency {
+		return 0, fmt.Errorf("%w: the cart is priced in %s", storage.ErrConflict, cartCurrency.String)
 	}
 
 	var existingQty int
 	var cartItemID int
-	err = p.Db.QueryRow(`
-		SELECT cart_item_id, quantity FROM cartItems WHERE cart_id = $1 AND product_id = $2
-	`, cartID, product_id).Scan(&cartItemID, &existingQty)
-
-	if err == nil && existingQty+quantity > stock {
-		return 0, fmt.Errorf("cannot add %d more, only %d left in stock", quantity, stock-existingQty)
-	}
-
-	subtotal := (price * float64(quantity)) - float64(discount)
-
-	if subtotal < 0 {
-		subtotal = 0
-	}
+	err = tx.QueryRow(`
+		SELECT cart_item_id, quantity FROM cartItems
+		WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+	`, cartID, product_id, nullableId(variant_id)).Scan(&cartItemID, &existingQty)
 
 	switch err {
 	case sql.ErrNoRows:
-		err = p.Db.QueryRow(`
-			INSERT INTO cartItems (cart_id, product_id, quantity, price_at_time, discount, subtotal)
-			VALUES ($1, $2, $3, $4, $5, $6)
+		err = tx.QueryRow(`
+			INSERT INTO cartItems (cart_id, product_id, variant_id, quantity, price_at_time, discount, subtotal, currency)
+			VALUES ($1, $2, $3, $4, $5, 0, $6, $7)
 			RETURNING cart_item_id
-		`, cartID, product_id, quantity, price, discount, subtotal).Scan(&cartItemID)
+		`, cartID, product_id, nullableId(variant_id), quantity, price.Amount, price.Times(int64(quantity)).Amount, price.Currency).Scan(&cartItemID)
 		if err != nil {
 			return 0, fmt.Errorf("failed to add item: %w", err)
 		}
 	case nil:
-		_, err = p.Db.Exec(`
-			UPDATE cartItems
-			SET quantity = quantity + $1,
-			    discount = $2,
-			    subtotal = subtotal + $3
-			WHERE cart_item_id = $4
-		`, quantity, discount, subtotal, cartItemID)
+		_, err = tx.Exec(`UPDATE cartItems SET quantity = quantity + $1 WHERE cart_item_id = $2`, quantity, cartItemID)
 		if err != nil {
 			return 0, fmt.Errorf("failed to update cart item: %w", err)
 		}
@@ -83,78 +86,205 @@ func (p *Postgres) AddToCart(user_id int, product_id int, quantity int, discount
 		return 0, fmt.Errorf("failed to check cart item: %w", err)
 	}
 
+	if err := p.reserveStock(tx, cartID, product_id, variant_id, existingQty+quantity); err != nil {
+		return 0, err
+	}
+
+	if _, _, err := p.recomputeCart(tx, cartID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return cartItemID, nil
 }
 
-func (p *Postgres) RemoveFromCart(user_id int, product_id int) error {
-
-	var cartId int
+// RemoveFromCart removes the cart line of a product, or of its variant when
+// variant_id is set, and releases its reservation.
+func (p *Postgres) RemoveFromCart(owner modules.CartOwner, product_id int, variant_id int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	err := p.Db.QueryRow(`SELECT cart_id FROM cartTable WHERE user_id = $1`, user_id).Scan(&cartId)
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return err
+	}
 
+	_, err = tx.Exec(`DELETE FROM cartItems WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3`, cartId, product_id, nullableId(variant_id))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("no cart found for the user")
-		}
-		return fmt.Errorf("error removing from the cart")
+		return fmt.Errorf("failed to remove cart item: %w", err)
 	}
 
-	stmt, err := p.Db.Prepare(`DELETE FROM cartItems WHERE cart_id = $1 AND product_id = $2`)
+	if _, err := releaseReservations(tx, "removed from cart", "cart_id = $2 AND product_id = $3 AND variant_id IS NOT DISTINCT FROM $4",
+		cartId, product_id, nullableId(variant_id)); err != nil {
+		return err
+	}
 
+	if _, _, err := p.recomputeCart(tx, cartId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateCartItemQuantity sets a cart line, and its reservation, to quantity,
+// removing both at zero. The line keeps the price it was added at; its
+// discount and subtotal, and the rest of the cart, are recomputed.
+func (p *Postgres) UpdateCartItemQuantity(owner modules.CartOwner, product_id int, variant_id int, quantity int) (modules.CartTotals, error) {
+	tx, err := p.Db.Begin()
 	if err != nil {
-		return fmt.Errorf("error removing from the cart")
+		return modules.CartTotals{}, err
 	}
+	defer tx.Rollback()
 
-	defer stmt.Close()
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
 
-	_, err = stmt.Exec(cartId, product_id)
+	var res sql.Result
+	if quantity == 0 {
+		res, err = tx.Exec(`
+			DELETE FROM cartItems WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+		`, cartId, product_id, nullableId(variant_id))
+	} else {
+		res, err = tx.Exec(`
+			UPDATE cartItems SET quantity = $4
+			WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+		`, cartId, product_id, nullableId(variant_id), quantity)
+	}
+	if err != nil {
+		return modules.CartTotals{}, fmt.Errorf("failed to update cart item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return modules.CartTotals{}, fmt.Errorf("%w: product %d is not in the cart", storage.ErrNotFound, product_id)
+	}
 
+	if quantity == 0 {
+		_, err = releaseReservations(tx, "removed from cart", "cart_id = $2 AND product_id = $3 AND variant_id IS NOT DISTINCT FROM $4",
+			cartId, product_id, nullableId(variant_id))
+	} else {
+		err = p.reserveStock(tx, cartId, product_id, variant_id, quantity)
+	}
 	if err != nil {
-		return fmt.Errorf("error removing from the cart")
+		return modules.CartTotals{}, err
 	}
 
-	return nil
+	totals, _, err := p.recomputeCart(tx, cartId)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
 
+	return totals, tx.Commit()
 }
 
-func (p *Postgres) FetchCartItems(user_id int) ([]modules.CartItem, []modules.Product, error) {
-	
-	rows, err := p.Db.Query(`SELECT ci.cart_item_id, ci.cart_id, ci.product_id, ci.quantity, ci.price_at_time, ci.discount, ci.subtotal, ci.added_at,
-					p.product_id, p.name, p.price, p.stock, p.category_id, p.quantity, p.brand, p.images
-					FROM cartItems ci
-					JOIN cartTable ct ON ci.cart_id = ct.cart_id
-					JOIN products p ON ci.product_id = p.product_id
-					WHERE ct.user_id = $1 AND ct.status = 'active'
-	`, user_id)
+// ClearCart empties the owner's active cart, releasing its reservations, and
+// removes its coupon. Clearing when there is no cart is a no-op.
+func (p *Postgres) ClearCart(owner modules.CartOwner) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	cartId, err := activeCartId(tx, owner)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("error fetching cart items: %w", err)
+		return err
 	}
-	defer rows.Close()
 
-	var cartItems []modules.CartItem
-	var products []modules.Product
+	if _, err := tx.Exec(`DELETE FROM cartItems WHERE cart_id = $1`, cartId); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM cart_promotions WHERE cart_id = $1`, cartId); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	if _, err := releaseReservations(tx, "cart cleared", "cart_id = $2", cartId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE cartTable SET coupon_code = NULL WHERE cart_id = $1`, cartId); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
 
-	for rows.Next() {
-		var ci modules.CartItem
-		var pr modules.Product
+	return tx.Commit()
+}
 
-		err := rows.Scan(&ci.CartItemId, &ci.CartId, &ci.ProductId, &ci.Quantity, &ci.PriceAtTime, &ci.Discount, &ci.Subtotal, &ci.AddedAt,
-		&pr.ProductId, &pr.Name, &pr.Price, &pr.Stock, &pr.CategoryID, &pr.Quantity, &pr.Brand, pq.Array(&pr.Images),
-		)
+// PlaceOrder turns the owner's active cart into an order. Its reservations
+// become sales, taken from the warehouses an allocation with strategy and
+// origin picks; its promotions are worked out one last time and those
+// applied are redeemed against the owner's per_user_limit. It fails with
+// ErrConflict when the cart is empty or a line can no longer be filled.
+func (p *Postgres) PlaceOrder(owner modules.CartOwner, strategy string, origin *modules.Location) (modules.Order, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.Order{}, err
+	}
+	defer tx.Rollback()
 
-		if err != nil {
-			return nil, nil, fmt.Errorf("error scanning row: %w", err)
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return modules.Order{}, err
+	}
+
+	lines, err := cartLineQuantities(tx, cartId)
+	if err != nil {
+		return modules.Order{}, err
+	}
+	if len(lines) == 0 {
+		return modules.Order{}, fmt.Errorf("%w: the cart is empty", storage.ErrConflict)
+	}
+
+	for _, line := range lines {
+		if err := p.reserveStock(tx, cartId, line.productId, line.variantId, line.quantity); err != nil {
+			return modules.Order{}, err
 		}
+	}
+	if _, err := releaseReservations(tx, "order placed", "cart_id = $2", cartId); err != nil {
+		return modules.Order{}, err
+	}
 
-		cartItems = append(cartItems, ci)
-		products = append(products, pr)
+	plan, err := allocateCart(tx, cartId, strategy, origin)
+	if err != nil {
+		return modules.Order{}, err
+	}
+	if len(plan.Shortfalls) > 0 {
+		return modules.Order{}, fmt.Errorf("%w: the warehouses are %d short of cart item %d", storage.ErrConflict, plan.Shortfalls[0].Quantity, plan.Shortfalls[0].CartItemId)
+	}
+	items := make(map[int]lineQuantity, len(lines))
+	for _, line := range lines {
+		items[line.id] = line
+	}
+	for _, a := range plan.Allocations {
+		item := items[a.CartItemId]
+		if _, err := moveStock(tx, item.productId, item.variantId, a.WarehouseId, -a.Quantity, modules.MovementSale, "order placed", owner.UserId); err != nil {
+			return modules.Order{}, err
+		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, nil, fmt.Errorf("row iteration error: %w", err)
+	totals, _, err := p.recomputeCart(tx, cartId)
+	if err != nil {
+		return modules.Order{}, err
+	}
+	if err := redeemPromotions(tx, cartId, owner, totals.Promotions); err != nil {
+		return modules.Order{}, err
 	}
 
-	return cartItems, products, nil 
+	if _, err := tx.Exec(`UPDATE cartTable SET status = 'ordered' WHERE cart_id = $1`, cartId); err != nil {
+		return modules.Order{}, fmt.Errorf("failed to place order: %w", err)
+	}
 
-} 
\ No newline at end of file
+	if err := tx.Commit(); err != nil {
+		return modules.Order{}, err
+	}
+
+	InvalidateProductCache()
+
+	return modules.Order{CartId: cartId, CartTotals: totals, Allocations: plan.Allocations}, nil
+}
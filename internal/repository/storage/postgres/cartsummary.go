@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// GetCart recomputes the owner's active cart and returns it with line
+// totals, tax, shipping and warnings for lines whose price has changed or
+// that the stock other carts have not reserved can no longer fill. Products
+// are priced for pc; the totals stay in the currency the cart was priced in.
+func (p *Postgres) GetCart(owner modules.CartOwner, pc modules.PriceContext) (modules.CartSummary, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.CartSummary{}, err
+	}
+	defer tx.Rollback()
+
+	cartId, err := activeCartId(tx, owner)
+	if errors.Is(err, storage.ErrNotFound) {
+		return p.summarizeCart(modules.CartSummary{Currency: p.currency}, modules.PriceContext{UserId: owner.UserId})
+	}
+	if err != nil {
+		return modules.CartSummary{}, err
+	}
+
+	totals, _, err := p.recomputeCart(tx, cartId)
+	if err != nil {
+		return modules.CartSummary{}, err
+	}
+
+	rows, err := tx.Query(`SELECT `+productColumns+`,
+			ci.cart_item_id, ci.variant_id, ci.quantity, ci.price_at_time, ci.discount, ci.subtotal, ci.added_at,
+			COALESCE(v.price, p.price), COALESCE(v.currency, p.currency), v.price IS NOT NULL, COALESCE(v.stock, p.stock) - COALESCE((
+				SELECT SUM(r.quantity) FROM stock_reservations r
+				WHERE r.product_id = ci.product_id AND r.variant_id IS NOT DISTINCT FROM ci.variant_id
+					AND r.cart_id <> ci.cart_id AND r.expires_at > NOW()), 0),
+			`+publicProduct+` AND v.deleted_at IS NULL
+		FROM cartItems ci
+		JOIN products p ON p.product_id = ci.product_id
+		LEFT JOIN product_variants v ON v.variant_id = ci.variant_id
+		WHERE ci.cart_id = $1
+		ORDER BY ci.cart_item_id
+	`, cartId)
+	if err != nil {
+		return modules.CartSummary{}, fmt.Errorf("error fetching cart items: %w", err)
+	}
+	defer rows.Close()
+
+	summary := modules.CartSummary{
+		CartId:     cartId,
+		Currency:   totals.Subtotal.Currency,
+		Coupon:     totals.Coupon,
+		Promotions: totals.Promotions,
+		Subtotal:   totals.Subtotal,
+		Discount:   totals.Discount,
+	}
+	var stored []modules.Money
+	var ownPrice, public []bool
+	for rows.Next() {
+		var line modules.CartLine
+		var price modules.Money
+		var own, live bool
+		line.Product, err = scanProduct(rows, &line.CartItemId, &line.VariantId, &line.Quantity, &line.UnitPrice.Amount, &line.Discount.Amount,
+			&line.Total.Amount, &line.AddedAt, &price.Amount, &price.Currency, &own, &line.Available, &live)
+		if err != nil {
+			return modules.CartSummary{}, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		line.ProductId = line.Product.ProductId
+		line.UnitPrice.Currency, line.Discount.Currency, line.Total.Currency = summary.Currency, summary.Currency, summary.Currency
+		line.LineTotal = line.UnitPrice.Times(int64(line.Quantity))
+		summary.Lines = append(summary.Lines, line)
+		stored, ownPrice, public = append(stored, price), append(ownPrice, own), append(public, live)
+	}
+	if err := rows.Err(); err != nil {
+		return modules.CartSummary{}, fmt.Errorf("row iteration error: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return modules.CartSummary{}, err
+	}
+
+	products := make([]modules.Product, len(summary.Lines))
+	ids := make([]int, len(summary.Lines))
+	for i, line := range summary.Lines {
+		products[i], ids[i] = line.Product, line.ProductId
+	}
+	if _, err := p.priceProducts(products, pc); err != nil {
+		return modules.CartSummary{}, err
+	}
+
+	// Current prices are compared in the cart's currency, for the cart's owner.
+	cartPc := modules.PriceContext{Currency: summary.Currency, UserId: owner.UserId}
+	resolver, err := p.newPriceResolver(cartPc, ids)
+	if err != nil {
+		return modules.CartSummary{}, err
+	}
+
+	summary.Savings = summary.Discount
+	for i := range summary.Lines {
+		line := &summary.Lines[i]
+		line.Product = products[i]
+
+		if !public[i] {
+			summary.Warnings = append(summary.Warnings, modules.CartWarning{CartItemId: line.CartItemId, Code: modules.CartWarningUnavailable,
+				Message: fmt.Sprintf("%s is no longer available", line.Product.Name)})
+			continue
+		}
+		if line.Quantity > line.Available {
+			summary.Warnings = append(summary.Warnings, modules.CartWarning{CartItemId: line.CartItemId, Code: modules.CartWarningInsufficientStock,
+				Message: fmt.Sprintf("only %d of %s left in stock", line.Available, line.Product.Name)})
+		}
+
+		effective, regular, err := resolver.price(line.ProductId, stored[i], ownPrice[i])
+		if errors.Is(err, storage.ErrUnsupportedCurrency) {
+			continue
+		}
+		if err != nil {
+			return modules.CartSummary{}, err
+		}
+
+		line.CurrentPrice = &effective
+		if effective.Amount != line.UnitPrice.Amount {
+			summary.Warnings = append(summary.Warnings, modules.CartWarning{CartItemId: line.CartItemId, Code: modules.CartWarningPriceChanged,
+				Message: fmt.Sprintf("the price of %s changed from %s to %s", line.Product.Name, line.UnitPrice, effective)})
+		}
+		if regular.Amount > line.UnitPrice.Amount {
+			summary.Savings.Amount += (regular.Amount - line.UnitPrice.Amount) * int64(line.Quantity)
+		}
+	}
+
+	return p.summarizeCart(summary, cartPc)
+}
+
+// summarizeCart adds tax, shipping and the grand total to summary. The
+// shipping fee and free shipping threshold are configured in the catalog
+// currency and converted to the cart's.
+func (p *Postgres) summarizeCart(summary modules.CartSummary, pc modules.PriceContext) (modules.CartSummary, error) {
+	currency := summary.Currency
+	if summary.Lines == nil {
+		summary.Lines = []modules.CartLine{}
+	}
+	if summary.Promotions == nil {
+		summary.Promotions = []modules.AppliedPromotion{}
+	}
+	if summary.Warnings == nil {
+		summary.Warnings = []modules.CartWarning{}
+	}
+	summary.Subtotal.Currency, summary.Discount.Currency, summary.Savings.Currency = currency, currency, currency
+	summary.Tax = modules.Money{Currency: currency}
+	summary.Shipping = modules.Money{Currency: currency}
+
+	net := summary.Subtotal.Amount - summary.Discount.Amount
+	rate := p.cart.TaxPercent
+	if p.cart.PricesIncludeTax {
+		summary.Tax.Amount = net - int64(math.Round(float64(net)*100/(100+rate)))
+	} else {
+		summary.Tax.Amount = int64(math.Round(float64(net) * rate / 100))
+	}
+
+	if len(summary.Lines) > 0 && p.cart.ShippingFee > 0 {
+		resolver := &priceResolver{currency: pc.Currency, rates: map[string]float64{}}
+		if resolver.currency != p.currency {
+			if err := p.loadRates(resolver); err != nil {
+				return modules.CartSummary{}, err
+			}
+		}
+
+		fee, err := resolver.convert(modules.Money{Amount: p.cart.ShippingFee, Currency: p.currency})
+		if err != nil {
+			return modules.CartSummary{}, err
+		}
+		threshold, err := resolver.convert(modules.Money{Amount: p.cart.FreeShippingOver, Currency: p.currency})
+		if err != nil {
+			return modules.CartSummary{}, err
+		}
+
+		if p.cart.FreeShippingOver == 0 || net < threshold.Amount {
+			summary.Shipping = fee
+		}
+	}
+
+	summary.GrandTotal = modules.Money{Amount: net + summary.Shipping.Amount, Currency: currency}
+	if !p.cart.PricesIncludeTax {
+		summary.GrandTotal.Amount += summary.Tax.Amount
+	}
+
+	return summary, nil
+}
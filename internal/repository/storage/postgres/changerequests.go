@@ -0,0 +1,314 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+const changeRequestColumns = `change_request_id, product_id, action, base_version, fields, proposed, sale, variant, price_list, reason, status,
+	requested_by, reviewed_by, review_note, created_at, reviewed_at`
+
+func scanChangeRequest(row rowScanner) (modules.ChangeRequest, error) {
+	var cr modules.ChangeRequest
+	err := row.Scan(&cr.ChangeRequestId, &cr.ProductId, &cr.Action, &cr.BaseVersion, pq.Array(&cr.Fields), jsonb{&cr.Proposed}, jsonb{&cr.Sale}, jsonb{&cr.Variant}, jsonb{&cr.PriceList}, &cr.Reason, &cr.Status,
+		&cr.RequestedBy, &cr.ReviewedBy, &cr.ReviewNote, &cr.CreatedAt, &cr.ReviewedAt)
+	return cr, err
+}
+
+// priceApprovalReason explains why a price change needs a second admin, or
+// returns "" when it does not. A change of currency always does.
+func priceApprovalReason(rules config.Approval, oldPrice modules.Money, newPrice modules.Money) string {
+	if rules.PriceChangePercent <= 0 {
+		return ""
+	}
+
+	if newPrice.Currency != "" && newPrice.Currency != oldPrice.Currency {
+		return fmt.Sprintf("price currency changes from %s to %s", oldPrice.Currency, newPrice.Currency)
+	}
+
+	if oldPrice.Amount == 0 || oldPrice.Amount == newPrice.Amount {
+		return ""
+	}
+
+	change := math.Abs(float64(newPrice.Amount-oldPrice.Amount)) / float64(oldPrice.Amount) * 100
+	if change <= rules.PriceChangePercent {
+		return ""
+	}
+
+	return fmt.Sprintf("price change of %.1f%% exceeds %.1f%%", change, rules.PriceChangePercent)
+}
+
+// holdPriceChange holds cr for approval when moving a price from oldPrice to
+// newPrice needs a second admin, and returns nil when the change may go
+// ahead. Every write that changes a product's regular, sale, variant or price
+// list price calls it.
+func (p *Postgres) holdPriceChange(tx *sql.Tx, oldPrice modules.Money, newPrice modules.Money, cr modules.ChangeRequest, requestedBy int) error {
+	cr.Reason = priceApprovalReason(p.approval, oldPrice, newPrice)
+	if cr.Reason == "" {
+		return nil
+	}
+	return holdChange(tx, cr, requestedBy)
+}
+
+// holdChange saves cr as a pending change request inside tx and returns it
+// as a *storage.HeldForApproval. The caller commits tx with commitHeld.
+func holdChange(tx *sql.Tx, cr modules.ChangeRequest, requestedBy int) error {
+	if requestedBy == 0 {
+		return fmt.Errorf("%w: sign in to make changes that need approval (%s)", storage.ErrUnauthorized, cr.Reason)
+	}
+
+	held, err := scanChangeRequest(tx.QueryRow(`
+		INSERT INTO product_change_requests (product_id, action, base_version, fields, proposed, sale, variant, price_list, reason, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+changeRequestColumns,
+		cr.ProductId, cr.Action, cr.BaseVersion, pq.Array(cr.Fields), jsonb{cr.Proposed}, jsonb{cr.Sale}, jsonb{cr.Variant}, jsonb{cr.PriceList}, cr.Reason, requestedBy))
+	if err != nil {
+		return fmt.Errorf("error creating change request: %w", err)
+	}
+
+	return &storage.HeldForApproval{ChangeRequest: held}
+}
+
+// commitHeld commits tx when err holds a change for approval, so that the
+// change request is kept, and returns err either way.
+func commitHeld(tx *sql.Tx, err error) error {
+	var held *storage.HeldForApproval
+	if errors.As(err, &held) {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// holdDeletion holds the deletion of product for approval when every
+// deletion needs one, or when actorId has already deleted MassDeleteCount
+// products within MassDeleteWindow.
+func (p *Postgres) holdDeletion(tx *sql.Tx, product modules.Product, actorId int) error {
+	cr := modules.ChangeRequest{ProductId: product.ProductId, BaseVersion: product.Version, Action: "delete", Proposed: product}
+
+	if p.approval.RequireForDelete {
+		cr.Reason = "deletions require approval"
+		return holdChange(tx, cr, actorId)
+	}
+
+	if p.approval.MassDeleteCount <= 0 {
+		return nil
+	}
+
+	var recent int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM product_revisions
+		WHERE action = 'delete' AND actor_id IS NOT DISTINCT FROM $1 AND changed_at > NOW() - $2 * INTERVAL '1 second'
+	`, nullableId(actorId), p.approval.MassDeleteWindow.Seconds()).Scan(&recent)
+	if err != nil {
+		return fmt.Errorf("error counting recent deletions: %w", err)
+	}
+
+	if recent < p.approval.MassDeleteCount {
+		return nil
+	}
+
+	cr.Reason = fmt.Sprintf("%d products deleted in the last %s", recent, p.approval.MassDeleteWindow)
+	return holdChange(tx, cr, actorId)
+}
+
+// GetChangeRequests lists change requests, newest first. An empty status
+// lists all of them.
+func (p *Postgres) GetChangeRequests(status string) ([]modules.ChangeRequest, error) {
+	rows, err := p.Db.Query(`
+		SELECT `+changeRequestColumns+`
+		FROM product_change_requests
+		WHERE $1 = '' OR status = $1
+		ORDER BY change_request_id DESC
+	`, status)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching change requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []modules.ChangeRequest
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning change request: %w", err)
+		}
+		requests = append(requests, cr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return requests, nil
+}
+
+// lockPendingChangeRequest loads a pending change request FOR UPDATE and
+// checks that reviewerId is an admin.
+func lockPendingChangeRequest(tx *sql.Tx, id int, reviewerId int) (modules.ChangeRequest, error) {
+	if reviewerId == 0 {
+		return modules.ChangeRequest{}, fmt.Errorf("%w: reviewer must sign in", storage.ErrUnauthorized)
+	}
+
+	var role string
+	err := tx.QueryRow(`SELECT role FROM users WHERE user_id = $1`, reviewerId).Scan(&role)
+	if err == sql.ErrNoRows || (err == nil && role != "admin") {
+		return modules.ChangeRequest{}, fmt.Errorf("%w: only admins can review change requests", storage.ErrForbidden)
+	}
+	if err != nil {
+		return modules.ChangeRequest{}, fmt.Errorf("error fetching reviewer: %w", err)
+	}
+
+	cr, err := scanChangeRequest(tx.QueryRow(`SELECT `+changeRequestColumns+` FROM product_change_requests WHERE change_request_id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return modules.ChangeRequest{}, fmt.Errorf("%w: change request %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return modules.ChangeRequest{}, fmt.Errorf("error fetching change request: %w", err)
+	}
+
+	if cr.Status != modules.ChangeRequestPending {
+		return modules.ChangeRequest{}, fmt.Errorf("%w: change request %d is already %s", storage.ErrConflict, id, cr.Status)
+	}
+
+	return cr, nil
+}
+
+func resolveChangeRequest(tx *sql.Tx, id int, status string, reviewerId int, note string) (modules.ChangeRequest, error) {
+	cr, err := scanChangeRequest(tx.QueryRow(`
+		UPDATE product_change_requests
+		SET status = $1, reviewed_by = $2, review_note = $3, reviewed_at = CURRENT_TIMESTAMP
+		WHERE change_request_id = $4
+		RETURNING `+changeRequestColumns, status, reviewerId, note, id))
+	if err != nil {
+		return modules.ChangeRequest{}, fmt.Errorf("error updating change request: %w", err)
+	}
+
+	return cr, nil
+}
+
+// ApproveChangeRequest applies a pending change on behalf of its requester.
+// The approver must be an admin other than the requester, and the product
+// must still be at the version the change was based on.
+func (p *Postgres) ApproveChangeRequest(id int, approverId int, note string) (modules.ChangeRequest, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+	defer tx.Rollback()
+
+	cr, err := lockPendingChangeRequest(tx, id, approverId)
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	requestedBy := 0
+	if cr.RequestedBy != nil {
+		requestedBy = *cr.RequestedBy
+	}
+	if requestedBy == approverId {
+		return modules.ChangeRequest{}, fmt.Errorf("%w: a change request must be approved by a different user", storage.ErrForbidden)
+	}
+
+	err = p.applyChangeRequest(tx, cr, requestedBy)
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	cr, err = resolveChangeRequest(tx, id, modules.ChangeRequestApproved, approverId, note)
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	InvalidateProductCacheById(cr.ProductId)
+	return cr, nil
+}
+
+// applyChangeRequest makes the change cr proposes without checking again
+// whether it needs approval.
+func (p *Postgres) applyChangeRequest(tx *sql.Tx, cr modules.ChangeRequest, requestedBy int) error {
+	switch cr.Action {
+	case "delete":
+		return softDeleteProduct(tx, cr.ProductId, cr.BaseVersion, requestedBy)
+	case "restore":
+		before, err := scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.product_id = $1 FOR UPDATE", cr.ProductId))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: product %d", storage.ErrNotFound, cr.ProductId)
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching product: %w", err)
+		}
+		if before.Version != cr.BaseVersion {
+			return fmt.Errorf("%w: product %d is at version %d", storage.ErrVersionMismatch, cr.ProductId, before.Version)
+		}
+		cr.Proposed.Stock = before.Stock
+		_, err = p.restoreSnapshot(tx, cr.ProductId, &before, cr.Proposed, requestedBy)
+		return err
+	case "price":
+		return setPriceIn(tx, cr.ProductId, cr.Proposed.Price, requestedBy)
+	case "sale":
+		if cr.Sale == nil {
+			return fmt.Errorf("change request %d has no sale", cr.ChangeRequestId)
+		}
+		_, err := insertSalePrice(tx, cr.ProductId, *cr.Sale)
+		return err
+	case "variant":
+		if cr.Variant == nil {
+			return fmt.Errorf("change request %d has no variant", cr.ChangeRequestId)
+		}
+		var err error
+		if cr.Variant.VariantId == 0 {
+			_, err = p.insertVariant(tx, *cr.Variant, requestedBy)
+		} else {
+			_, err = p.updateVariant(tx, *cr.Variant, requestedBy)
+		}
+		return err
+	case "price_list":
+		if cr.PriceList == nil {
+			return fmt.Errorf("change request %d has no price list", cr.ChangeRequestId)
+		}
+		_, err := savePriceList(tx, *cr.PriceList)
+		return err
+	default:
+		before, err := lockProduct(tx, cr.ProductId, cr.BaseVersion)
+		if err != nil {
+			return err
+		}
+		_, err = p.applyPatch(tx, before, productChanges(cr.Proposed, cr.Fields), requestedBy)
+		return err
+	}
+}
+
+func (p *Postgres) RejectChangeRequest(id int, reviewerId int, note string) (modules.ChangeRequest, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockPendingChangeRequest(tx, id, reviewerId); err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	cr, err := resolveChangeRequest(tx, id, modules.ChangeRequestRejected, reviewerId, note)
+	if err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.ChangeRequest{}, err
+	}
+
+	return cr, nil
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// MergeGuestCart moves a guest's active cart into the user's. Without a user
+// cart the guest cart, and its reservations, simply become theirs. Otherwise
+// each guest line is repriced for the user in the user cart's currency,
+// combined with any matching line according to strategy, capped at the stock
+// other carts leave and reserved; lines that can no longer be bought are
+// dropped. Having no guest cart is a no-op.
+func (p *Postgres) MergeGuestCart(guestId string, userId int, strategy string) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guestCartId, err := activeCartId(tx, modules.CartOwner{GuestId: guestId})
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	userCartId, err := activeCartId(tx, modules.CartOwner{UserId: userId})
+	if errors.Is(err, storage.ErrNotFound) {
+		if _, err := tx.Exec(`UPDATE cartTable SET user_id = $1, guest_token = NULL WHERE cart_id = $2`, userId, guestCartId); err != nil {
+			return fmt.Errorf("failed to claim guest cart: %w", err)
+		}
+		if _, _, err := p.recomputeCart(tx, guestCartId); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	var currency string
+	err = tx.QueryRow(`
+		SELECT COALESCE((SELECT MIN(currency) FROM cartItems WHERE cart_id = $1), (SELECT MIN(currency) FROM cartItems WHERE cart_id = $2), '')
+	`, userCartId, guestCartId).Scan(&currency)
+	if err != nil {
+		return fmt.Errorf("failed to check cart currency: %w", err)
+	}
+
+	lines, err := cartLineQuantities(tx, guestCartId)
+	if err != nil {
+		return err
+	}
+
+	// The guest cart's reservations go with it; the merged lines are
+	// reserved for the user cart instead.
+	if _, err := releaseReservations(tx, "merged into user cart", "cart_id = $2", guestCartId); err != nil {
+		return err
+	}
+
+	pc := modules.PriceContext{Currency: currency, UserId: userId}
+	for _, line := range lines {
+		price, _, err := p.variantPriceAndStock(line.productId, line.variantId, pc)
+		if err != nil {
+			continue
+		}
+		stock, err := lockAvailableStock(tx, userCartId, line.productId, line.variantId)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var cartItemId, existing int
+		err = tx.QueryRow(`
+			SELECT cart_item_id, quantity FROM cartItems
+			WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+		`, userCartId, line.productId, nullableId(line.variantId)).Scan(&cartItemId, &existing)
+		found := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check cart item: %w", err)
+		}
+
+		quantity := mergedQuantity(strategy, existing, line.quantity, found)
+		if quantity > stock {
+			quantity = stock
+		}
+		if quantity <= 0 || (found && quantity == existing) {
+			continue
+		}
+
+		if found {
+			_, err = tx.Exec(`UPDATE cartItems SET quantity = $1 WHERE cart_item_id = $2`, quantity, cartItemId)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO cartItems (cart_id, product_id, variant_id, quantity, price_at_time, discount, subtotal, currency)
+				VALUES ($1, $2, $3, $4, $5, 0, $6, $7)
+			`, userCartId, line.productId, nullableId(line.variantId), quantity, price.Amount, price.Times(int64(quantity)).Amount, price.Currency)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to merge cart item: %w", err)
+		}
+		if err := p.holdStock(tx, userCartId, line.productId, line.variantId, quantity); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM cartTable WHERE cart_id = $1`, guestCartId); err != nil {
+		return fmt.Errorf("failed to remove guest cart: %w", err)
+	}
+	if _, _, err := p.recomputeCart(tx, userCartId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// mergedQuantity combines a guest line's quantity with the user's. found is
+// false when the user's cart has no such line.
+func mergedQuantity(strategy string, user int, guest int, found bool) int {
+	if !found {
+		return guest
+	}
+	switch strategy {
+	case modules.CartMergeMax:
+		return max(user, guest)
+	case modules.CartMergeUser:
+		return user
+	case modules.CartMergeGuest:
+		return guest
+	default:
+		return user + guest
+	}
+}
+
+// PurgeGuestCarts deletes active guest carts left unused for more than ttl.
+// Guest carts that became orders are kept.
+func (p *Postgres) PurgeGuestCarts(ttl time.Duration) (int, error) {
+	cutoff := time.Now().Add(-ttl)
+	_, err := releaseReservations(p.Db, "guest cart expired", "cart_id IN (SELECT cart_id FROM cartTable WHERE guest_token IS NOT NULL AND status = 'active' AND updated_at < $2)", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := p.Db.Exec(`DELETE FROM cartTable WHERE guest_token IS NOT NULL AND status = 'active' AND updated_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("error purging guest carts: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// ImportProducts upserts a batch of products in one transaction, matching
+// existing products by SKU, or by external id for rows without a SKU. Each
+// row runs under a savepoint so a failing row is reported without aborting
+// the rest of the batch. Updates write only the fields the row supplied and
+// changed, so re-running an import leaves its rows unchanged, and those that
+// need approval are held as change requests. A dry run performs the same
+// work and rolls it back. Results are returned in batch order.
+func (p *Postgres) ImportProducts(batch []modules.ImportRow, dryRun bool, actorId int) ([]modules.ImportResult, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]modules.ImportResult, len(batch))
+	written := false
+
+	for i, row := range batch {
+		result := &results[i]
+		result.SKU, result.ExternalId = row.Product.SKU, row.Product.ExternalId
+
+		if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+
+		productId, action, err := p.upsertProduct(tx, row, actorId)
+		var held *storage.HeldForApproval
+		if errors.As(err, &held) {
+			productId, action, err = held.ChangeRequest.ProductId, modules.ImportHeld, nil
+			if !dryRun {
+				result.ChangeRequestId = held.ChangeRequest.ChangeRequestId
+			}
+		}
+		if err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); rbErr != nil {
+				return nil, rbErr
+			}
+			result.Action, result.Error = modules.ImportFailed, err.Error()
+			continue
+		}
+
+		if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+		result.ProductId, result.Action = productId, action
+		written = true
+	}
+
+	if dryRun || !written {
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	InvalidateProductCache()
+	return results, nil
+}
+
+func (p *Postgres) upsertProduct(tx *sql.Tx, row modules.ImportRow, actorId int) (int, string, error) {
+	product := row.Product
+	column, key := "sku", product.SKU
+	if key == "" {
+		column, key = "external_id", product.ExternalId
+	}
+	if key == "" {
+		return 0, "", fmt.Errorf("sku or external_id is required")
+	}
+
+	var productId, version int
+	var deleted bool
+	err := tx.QueryRow("SELECT product_id, version, deleted_at IS NOT NULL FROM products WHERE "+column+" = $1", key).Scan(&productId, &version, &deleted)
+	switch {
+	case err == sql.ErrNoRows:
+		created, err := p.insertProduct(tx, product, actorId)
+		if err != nil {
+			return 0, "", err
+		}
+		return created.ProductId, modules.ImportCreated, nil
+	case err != nil:
+		return 0, "", fmt.Errorf("error looking up %s %q: %w", column, key, err)
+	case deleted:
+		return 0, "", fmt.Errorf("%s %q belongs to a deleted product, restore it first", column, key)
+	}
+
+	current, err := lockProduct(tx, productId, version)
+	if err != nil {
+		return 0, "", err
+	}
+
+	changes := importChanges(product, row.Fields)
+	if price, ok := changes["price"].(modules.Money); ok {
+		price.Currency = p.currencyOf(price)
+		changes["price"] = price
+	}
+	changes = dropUnchanged(current, changes)
+	if len(changes) == 0 {
+		return productId, modules.ImportUnchanged, nil
+	}
+
+	if _, err := p.patchProduct(tx, productId, version, changes, actorId); err != nil {
+		return 0, "", err
+	}
+	return productId, modules.ImportUpdated, nil
+}
+
+// importChanges picks the fields an import row supplied out of product, as
+// patchProduct takes them, so columns missing from the file keep their
+// current values.
+func importChanges(product modules.Product, fields []string) map[string]any {
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if patchableColumns[field] {
+			columns = append(columns, field)
+		}
+	}
+	return productChanges(product, columns)
+}
+
+// dropUnchanged removes the changes that would leave before as it is, so
+// importing the same row again writes nothing and records no revision.
+func dropUnchanged(before modules.Product, changes map[string]any) map[string]any {
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		columns = append(columns, column)
+	}
+	current := productChanges(before, columns)
+
+	for column, value := range changes {
+		if sameValue(current[column], value) {
+			delete(changes, column)
+		}
+	}
+	return changes
+}
+
+// sameValue reports whether two column values are equal, taking empty
+// slices and maps as equal to nil and comparing times by instant.
+func sameValue(a, b any) bool {
+	if t, ok := a.(*time.Time); ok {
+		u, _ := b.(*time.Time)
+		return t == nil && u == nil || t != nil && u != nil && t.Equal(*u)
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == vb.Kind() && (va.Kind() == reflect.Slice || va.Kind() == reflect.Map) && va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/importer"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+func TestImportChangesKeepMissingColumns(t *testing.T) {
+	existing := modules.Product{
+		ProductId:  7,
+		SKU:        "TSHIRT-1",
+		Name:       "T-shirt",
+		Price:      modules.Money{Amount: 1999, Currency: "USD"},
+		Stock:      10,
+		CategoryID: "apparel",
+		Quantity:   1,
+		Brand:      "Acme",
+		Images:     []modules.ProductImage{{URL: "https://cdn.example.com/tshirt.jpg"}},
+		Attributes: map[string]any{"colour": "red"},
+		Status:     modules.StatusDraft,
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  modules.Product
+	}{
+		{
+			name:  "partial csv row",
+			input: "sku,name,stock,category_id,quantity,brand\nTSHIRT-1,Plain T-shirt,4,apparel,1,Acme\n",
+			want: func() modules.Product {
+				want := existing
+				want.Name, want.Stock = "Plain T-shirt", 4
+				return want
+			}(),
+		},
+		{
+			name:  "csv row with status",
+			input: "sku,name,stock,category_id,quantity,brand,status\nTSHIRT-1,T-shirt,10,apparel,1,Acme,archived\n",
+			want: func() modules.Product {
+				want := existing
+				want.Status = modules.StatusArchived
+				return want
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := importer.NewReader("csv", strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			row, err := reader.Next()
+			if err != nil || row.Err != nil {
+				t.Fatalf("Next: %v %v", err, row.Err)
+			}
+
+			got := withChanges(existing, importChanges(row.Product, row.Fields))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropUnchanged(t *testing.T) {
+	publishAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	existing := modules.Product{
+		Name:       "T-shirt",
+		Price:      modules.Money{Amount: 1999, Currency: "USD"},
+		Stock:      10,
+		Attributes: map[string]any{"colour": "red"},
+		PublishAt:  &publishAt,
+	}
+	sameInstant := publishAt.In(time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name    string
+		changes map[string]any
+		want    []string
+	}{
+		{
+			name: "re-imported row",
+			changes: map[string]any{
+				"name": "T-shirt", "price": modules.Money{Amount: 1999, Currency: "USD"}, "stock": 10,
+				"attributes": map[string]any{"colour": "red"}, "publish_at": &sameInstant, "images": []modules.ProductImage{},
+			},
+			want: []string{},
+		},
+		{
+			name:    "changed columns are kept",
+			changes: map[string]any{"name": "Plain T-shirt", "price": modules.Money{Amount: 1999, Currency: "EUR"}, "stock": 10},
+			want:    []string{"name", "price"},
+		},
+		{
+			name:    "clearing publish_at is a change",
+			changes: map[string]any{"publish_at": (*time.Time)(nil)},
+			want:    []string{"publish_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dropUnchanged(existing, tt.changes)
+			columns := make([]string, 0, len(got))
+			for column := range got {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+			if !reflect.DeepEqual(columns, tt.want) {
+				t.Errorf("got %v, want %v", columns, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+// onHandMovements are the movement types that change stock on hand, in
+// total or in one warehouse.
+const onHandMovements = `type IN ('receipt', 'sale', 'adjustment', 'return', 'transfer')`
+
+const movementColumns = `movement_id, product_id, variant_id, warehouse_id, type, quantity, reason, actor_id, cart_id, created_at`
+
+func scanMovement(row rowScanner) (modules.InventoryMovement, error) {
+	var m modules.InventoryMovement
+	err := row.Scan(&m.MovementId, &m.ProductId, &m.VariantId, &m.WarehouseId, &m.Type, &m.Quantity, &m.Reason, &m.ActorId, &m.CartId, &m.CreatedAt)
+	return m, err
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// recordStockChange records a product write that changed its stock: the
+// initial stock of a new product as a receipt, later changes as adjustments.
+// The change is spread over warehouses as shiftStock does.
+func recordStockChange(tx *sql.Tx, action string, before, after *modules.Product, actorId int) error {
+	if after == nil {
+		return nil
+	}
+
+	movementType, reason, from := modules.MovementAdjustment, "product "+action, 0
+	if before == nil {
+		movementType, reason = modules.MovementReceipt, "initial stock"
+	} else {
+		from = before.Stock
+	}
+	if after.Stock == from {
+		return nil
+	}
+
+	_, err := moveStock(tx, after.ProductId, 0, 0, after.Stock-from, movementType, reason, actorId)
+	return err
+}
+
+// releaseReservations deletes the reservations matching where, whose
+// placeholders start at $2, and records each as a release with reason. It
+// returns how many it released.
+func releaseReservations(db execer, reason string, where string, args ...any) (int, error) {
+	res, err := db.Exec(`
+		WITH released AS (DELETE FROM stock_reservations WHERE `+where+` RETURNING cart_id, product_id, variant_id, quantity)
+		INSERT INTO inventory_movements (product_id, variant_id, type, quantity, reason, cart_id)
+		SELECT product_id, variant_id, 'release', -quantity, $1, cart_id FROM released
+	`, append([]any{reason}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release reservations: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// PostInventoryMovement applies a receipt, sale, adjustment or return to a
+// product's or variant's stock and records it in the ledger, one movement
+// per warehouse touched. Without a warehouse, stock is received into the
+// first and taken from those holding the most. It fails with ErrConflict
+// rather than take stock below zero.
+func (p *Postgres) PostInventoryMovement(productId int, movement modules.InventoryMovement, actorId int) ([]modules.InventoryMovement, error) {
+	delta := movement.Quantity
+	if movement.Type == modules.MovementSale {
+		delta = -delta
+	}
+	variantId, warehouseId := 0, 0
+	if movement.VariantId != nil {
+		variantId = *movement.VariantId
+	}
+	if movement.WarehouseId != nil {
+		warehouseId = *movement.WarehouseId
+	}
+
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockStockRow(tx, productId, variantId); err != nil {
+		return nil, err
+	}
+
+	posted, err := moveStock(tx, productId, variantId, warehouseId, delta, movement.Type, movement.Reason, actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	InvalidateProductCacheById(productId)
+	return posted, nil
+}
+
+// GetInventoryMovements returns a product's ledger, variants included,
+// newest first.
+func (p *Postgres) GetInventoryMovements(productId int) ([]modules.InventoryMovement, error) {
+	rows, err := p.Db.Query(`
+		SELECT `+movementColumns+` FROM inventory_movements
+		WHERE product_id = $1
+		ORDER BY movement_id DESC
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []modules.InventoryMovement{}
+	for rows.Next() {
+		m, err := scanMovement(rows)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, rows.Err()
+}
+
+// ReconcileInventory compares the stock of every product and variant not
+// deleted with the sum of its on-hand movements and returns those that differ. With fix, the
+// ledger wins: the difference is added to, or taken from, the warehouses
+// without recording a movement, since the ledger already has it.
+func (p *Postgres) ReconcileInventory(fix bool) ([]modules.StockDiscrepancy, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT s.product_id, s.variant_id, s.stock, COALESCE(l.total, 0)
+		FROM (
+			SELECT product_id, NULL::INT AS variant_id, stock FROM products WHERE deleted_at IS NULL
+			UNION ALL
+			SELECT v.product_id, v.variant_id, v.stock FROM product_variants v
+			JOIN products p ON p.product_id = v.product_id
+			WHERE v.deleted_at IS NULL AND p.deleted_at IS NULL
+		) s
+		LEFT JOIN (
+			SELECT product_id, variant_id, SUM(quantity) AS total FROM inventory_movements
+			WHERE ` + onHandMovements + `
+			GROUP BY product_id, variant_id
+		) l ON l.product_id = s.product_id AND l.variant_id IS NOT DISTINCT FROM s.variant_id
+		WHERE s.stock <> COALESCE(l.total, 0)
+		ORDER BY s.product_id, s.variant_id NULLS FIRST
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error reconciling inventory: %w", err)
+	}
+
+	discrepancies := []modules.StockDiscrepancy{}
+	for rows.Next() {
+		var d modules.StockDiscrepancy
+		if err := rows.Scan(&d.ProductId, &d.VariantId, &d.Stock, &d.Ledger); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !fix || len(discrepancies) == 0 {
+		return discrepancies, nil
+	}
+
+	for _, d := range discrepancies {
+		variantId := 0
+		if d.VariantId != nil {
+			variantId = *d.VariantId
+		}
+		if err := lockStockRow(tx, d.ProductId, variantId); err != nil {
+			return nil, err
+		}
+		if _, err := shiftStock(tx, d.ProductId, variantId, 0, d.Ledger-d.Stock); err != nil {
+			return nil, fmt.Errorf("failed to reconcile stock of product %d: %w", d.ProductId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	InvalidateProductCache()
+	return discrepancies, nil
+}
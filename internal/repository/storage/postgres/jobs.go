@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+const jobColumns = `job_id, type, status, params, progress, total, result, error, cancel_requested, attempts,
+	created_by, created_at, started_at, finished_at`
+
+func scanJob(row rowScanner) (modules.Job, error) {
+	var job modules.Job
+	var params, result []byte
+	err := row.Scan(&job.JobId, &job.Type, &job.Status, &params, &job.Progress, &job.Total, &result, &job.Error, &job.CancelRequested, &job.Attempts,
+		&job.CreatedBy, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	job.Params, job.Result = params, result
+	return job, err
+}
+
+func (p *Postgres) CreateJob(jobType string, params any, createdBy int) (modules.Job, error) {
+	job, err := scanJob(p.Db.QueryRow(`
+		INSERT INTO jobs (type, params, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING `+jobColumns,
+		jobType, jsonb{params}, nullableId(createdBy)))
+	if err != nil {
+		return modules.Job{}, fmt.Errorf("error creating job: %w", err)
+	}
+
+	return job, nil
+}
+
+func (p *Postgres) GetJob(id int) (modules.Job, error) {
+	job, err := scanJob(p.Db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE job_id = $1`, id))
+	if err == sql.ErrNoRows {
+		return modules.Job{}, fmt.Errorf("%w: job with id %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return modules.Job{}, fmt.Errorf("error fetching job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ClaimJob marks the oldest queued job as running and returns it. Workers in
+// other processes skip rows already locked, so each job is claimed once.
+// ErrNotFound means the queue is empty.
+func (p *Postgres) ClaimJob() (modules.Job, error) {
+	job, err := scanJob(p.Db.QueryRow(`
+		UPDATE jobs
+		SET status = 'running', started_at = NOW(), attempts = attempts + 1
+		WHERE job_id = (
+			SELECT job_id FROM jobs
+			WHERE status = 'queued'
+			ORDER BY job_id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns))
+	if err == sql.ErrNoRows {
+		return modules.Job{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return modules.Job{}, fmt.Errorf("error claiming job: %w", err)
+	}
+
+	return job, nil
+}
+
+// UpdateJobProgress stores a running job's progress and reports whether its
+// cancellation has been requested.
+func (p *Postgres) UpdateJobProgress(id int, progress int, total int) (bool, error) {
+	var cancelRequested bool
+	err := p.Db.QueryRow(`
+		UPDATE jobs SET progress = $1, total = $2
+		WHERE job_id = $3 AND status = 'running'
+		RETURNING cancel_requested
+	`, progress, total, id).Scan(&cancelRequested)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error updating job progress: %w", err)
+	}
+
+	return cancelRequested, nil
+}
+
+func (p *Postgres) FinishJob(id int, status string, result any, errMsg string) error {
+	var resultValue any
+	if result != nil {
+		resultValue = jsonb{result}
+	}
+
+	_, err := p.Db.Exec(`
+		UPDATE jobs SET status = $1, result = $2, error = $3, finished_at = NOW()
+		WHERE job_id = $4 AND status = 'running'
+	`, status, resultValue, errMsg, id)
+	if err != nil {
+		return fmt.Errorf("error finishing job: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueJob puts a running job back in the queue, e.g. when the worker
+// running it shuts down.
+func (p *Postgres) RequeueJob(id int) error {
+	_, err := p.Db.Exec(`UPDATE jobs SET status = 'queued', started_at = NULL WHERE job_id = $1 AND status = 'running'`, id)
+	if err != nil {
+		return fmt.Errorf("error requeueing job: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueRunningJobs requeues jobs left running by a process that stopped
+// without a clean shutdown. It is called once at startup and assumes a single
+// instance runs the workers.
+func (p *Postgres) RequeueRunningJobs() (int, error) {
+	res, err := p.Db.Exec(`UPDATE jobs SET status = 'queued', started_at = NULL WHERE status = 'running'`)
+	if err != nil {
+		return 0, fmt.Errorf("error requeueing jobs: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// CancelJob cancels a queued job at once; a running job is flagged and stops
+// at its next progress update.
+func (p *Postgres) CancelJob(id int) (modules.Job, error) {
+	job, err := scanJob(p.Db.QueryRow(`
+		UPDATE jobs
+		SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END
+		WHERE job_id = $1 AND status IN ('queued', 'running')
+		RETURNING `+jobColumns, id))
+	if err == sql.ErrNoRows {
+		existing, err := p.GetJob(id)
+		if err != nil {
+			return modules.Job{}, err
+		}
+		return modules.Job{}, fmt.Errorf("%w: job %d is already %s", storage.ErrConflict, id, existing.Status)
+	}
+	if err != nil {
+		return modules.Job{}, fmt.Errorf("error cancelling job: %w", err)
+	}
+
+	return job, nil
+}
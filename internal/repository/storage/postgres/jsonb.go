@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonb adapts any Go value to a JSONB column. Pass a pointer when scanning.
+type jsonb struct {
+	v any
+}
+
+func (j jsonb) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (j jsonb) Scan(src any) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, j.v)
+	case string:
+		return json.Unmarshal([]byte(data), j.v)
+	default:
+		return fmt.Errorf("cannot scan %T into jsonb", src)
+	}
+}
+
+// nullableId maps the zero id used by handlers to SQL NULL.
+func nullableId(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -5,12 +5,23 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/lib/pq"
 	"github.com/nkchakradhari780/catalogServices/internal/cache"
 	"github.com/nkchakradhari780/catalogServices/internal/config"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
 )
 
 type Postgres struct {
 	Db *sql.DB
+
+	// currency is used for prices written without one.
+	currency string
+
+	// cart holds the tax and shipping rules for cart summaries.
+	cart config.Cart
+
+	// approval decides which product changes are held for a second admin.
+	approval config.Approval
 }
 
 func New(cfg *config.Config) (*Postgres, error) {
@@ -53,6 +64,85 @@ func New(cfg *config.Config) (*Postgres, error) {
 			images       TEXT[]
 		)`,
 
+		// Images moved from TEXT[] of URLs to a JSONB array of image objects.
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'products' AND column_name = 'images' AND data_type = 'ARRAY') THEN
+				ALTER TABLE products ADD COLUMN images_json JSONB NOT NULL DEFAULT '[]';
+				UPDATE products SET images_json = COALESCE((SELECT jsonb_agg(jsonb_build_object('url', u)) FROM unnest(images) AS u), '[]');
+				ALTER TABLE products DROP COLUMN images;
+				ALTER TABLE products RENAME COLUMN images_json TO images;
+			END IF;
+		END $$`,
+
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}'`,
+		`CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN (attributes jsonb_path_ops)`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at) WHERE deleted_at IS NOT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published', 'archived'))`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_products_scheduled ON products (publish_at) WHERE status = 'scheduled'`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64) UNIQUE`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS external_id TEXT UNIQUE`,
+
+		`CREATE TABLE IF NOT EXISTS product_revisions (
+			revision_id  SERIAL PRIMARY KEY,
+			product_id   INT NOT NULL,
+			action       TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore')),
+			actor_id     INT REFERENCES users(user_id) ON DELETE SET NULL,
+			changed_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			diff         JSONB NOT NULL DEFAULT '{}',
+			snapshot     JSONB NOT NULL
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_revisions_product ON product_revisions (product_id, revision_id)`,
+
+		`CREATE TABLE IF NOT EXISTS product_change_requests (
+			change_request_id  SERIAL PRIMARY KEY,
+			product_id         INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			action             TEXT NOT NULL CHECK (action IN ('update', 'delete')),
+			base_version       INT NOT NULL,
+			fields             TEXT[],
+			proposed           JSONB NOT NULL,
+			reason             TEXT NOT NULL DEFAULT '',
+			status             TEXT NOT NULL CHECK (status IN ('pending', 'approved', 'rejected')) DEFAULT 'pending',
+			requested_by       INT REFERENCES users(user_id) ON DELETE SET NULL,
+			reviewed_by        INT REFERENCES users(user_id) ON DELETE SET NULL,
+			review_note        TEXT NOT NULL DEFAULT '',
+			created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			reviewed_at        TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS category_attribute_schemas (
+			category_id  INT PRIMARY KEY,
+			schema       JSONB NOT NULL DEFAULT '{}',
+			updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS product_variants (
+			variant_id   SERIAL PRIMARY KEY,
+			product_id   INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			sku          VARCHAR(64) UNIQUE NOT NULL,
+			options      JSONB NOT NULL DEFAULT '{}',
+			price        INT,
+			stock        INT NOT NULL DEFAULT 0,
+			images       TEXT[]
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_variants_product ON product_variants (product_id)`,
+
+		// Variant images moved to image objects, as product images did.
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'product_variants' AND column_name = 'images' AND data_type = 'ARRAY') THEN
+				ALTER TABLE product_variants ADD COLUMN images_json JSONB NOT NULL DEFAULT '[]';
+				UPDATE product_variants SET images_json = COALESCE((SELECT jsonb_agg(jsonb_build_object('url', u)) FROM unnest(images) AS u), '[]');
+				ALTER TABLE product_variants DROP COLUMN images;
+				ALTER TABLE product_variants RENAME COLUMN images_json TO images;
+			END IF;
+		END $$`,
+
 		`CREATE TABLE IF NOT EXISTS cartTable (
 			cart_id     SERIAL PRIMARY KEY,			
 			user_id     INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
@@ -65,6 +155,7 @@ func New(cfg *config.Config) (*Postgres, error) {
 			cart_item_id  SERIAL PRIMARY KEY, 		
 			cart_id       INT NOT NULL REFERENCES cartTable(cart_id) ON DELETE CASCADE,
 			product_id    INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			variant_id    INT REFERENCES product_variants(variant_id) ON DELETE CASCADE,
 			quantity      INT NOT NULL,
 			price_at_time FLOAT NOT NULL,
 			discount      FLOAT DEFAULT NULL,
@@ -76,9 +167,289 @@ func New(cfg *config.Config) (*Postgres, error) {
 			wish_list_id SERIAL PRIMARY KEY, 		
 			product_id   INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
 			user_id      INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
-			added_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			CONSTRAINT unique_user_product UNIQUE (user_id, product_id)
+			variant_id   INT REFERENCES product_variants(variant_id) ON DELETE CASCADE,
+			added_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS jobs (
+			job_id            SERIAL PRIMARY KEY,
+			type              TEXT NOT NULL,
+			status            TEXT NOT NULL CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')) DEFAULT 'queued',
+			params            JSONB NOT NULL DEFAULT '{}',
+			progress          INT NOT NULL DEFAULT 0,
+			total             INT NOT NULL DEFAULT 0,
+			result            JSONB,
+			error             TEXT NOT NULL DEFAULT '',
+			cancel_requested  BOOLEAN NOT NULL DEFAULT FALSE,
+			attempts          INT NOT NULL DEFAULT 0,
+			created_by        INT REFERENCES users(user_id) ON DELETE SET NULL,
+			created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			started_at        TIMESTAMP,
+			finished_at       TIMESTAMP
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs (job_id) WHERE status = 'queued'`,
+
+		// Variant support for databases created before product_variants existed.
+		`ALTER TABLE cartItems ADD COLUMN IF NOT EXISTS variant_id INT REFERENCES product_variants(variant_id) ON DELETE CASCADE`,
+		`ALTER TABLE wishList ADD COLUMN IF NOT EXISTS variant_id INT REFERENCES product_variants(variant_id) ON DELETE CASCADE`,
+		`ALTER TABLE wishList DROP CONSTRAINT IF EXISTS unique_user_product`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS unique_user_product_variant ON wishList (user_id, product_id, (COALESCE(variant_id, 0)))`,
+
+		// Prices moved to integer minor units with a currency. Existing
+		// whole-unit INT and FLOAT amounts are converted in the default currency.
+		moneyMigration(cfg.Catalog.Currency),
+
+		// Revisions and change requests saved before then keep whole-unit
+		// prices in their JSON, which Money no longer decodes.
+		legacyPriceMigration("product_revisions", "snapshot", cfg.Catalog.Currency),
+		legacyPriceMigration("product_change_requests", "proposed", cfg.Catalog.Currency),
+
+		`CREATE TABLE IF NOT EXISTS product_prices (
+			product_id  INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			currency    CHAR(3) NOT NULL,
+			amount      BIGINT NOT NULL CHECK (amount >= 0),
+			updated_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (product_id, currency)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+			from_currency  CHAR(3) NOT NULL,
+			to_currency    CHAR(3) NOT NULL,
+			rate           NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+			updated_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (from_currency, to_currency)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS user_groups (
+			group_id    SERIAL PRIMARY KEY,
+			name        TEXT UNIQUE NOT NULL,
+			created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS user_group_members (
+			group_id  INT NOT NULL REFERENCES user_groups(group_id) ON DELETE CASCADE,
+			user_id   INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_user_group_members_user ON user_group_members (user_id)`,
+
+		`CREATE TABLE IF NOT EXISTS price_lists (
+			price_list_id  SERIAL PRIMARY KEY,
+			name           TEXT NOT NULL,
+			group_id       INT NOT NULL REFERENCES user_groups(group_id) ON DELETE CASCADE,
+			currency       CHAR(3) NOT NULL,
+			starts_at      TIMESTAMP,
+			ends_at        TIMESTAMP,
+			created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+			CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS price_list_items (
+			price_list_id  INT NOT NULL REFERENCES price_lists(price_list_id) ON DELETE CASCADE,
+			product_id     INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			amount         BIGINT NOT NULL CHECK (amount >= 0),
+			PRIMARY KEY (price_list_id, product_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS product_sales (
+			sale_id     SERIAL PRIMARY KEY,
+			product_id  INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			amount      BIGINT NOT NULL CHECK (amount >= 0),
+			currency    CHAR(3) NOT NULL,
+			starts_at   TIMESTAMP NOT NULL,
+			ends_at     TIMESTAMP,
+			created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+			CHECK (ends_at IS NULL OR ends_at > starts_at)
 		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_sales_product ON product_sales (product_id)`,
+
+		`CREATE TABLE IF NOT EXISTS price_history (
+			price_history_id  SERIAL PRIMARY KEY,
+			product_id        INT NOT NULL,
+			amount            BIGINT NOT NULL,
+			currency          CHAR(3) NOT NULL,
+			changed_by        INT REFERENCES users(user_id) ON DELETE SET NULL,
+			changed_at        TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_price_history_product ON price_history (product_id, currency, changed_at)`,
+
+		// Sale and price list offers are kept in the history too, from when
+		// they took effect until ends_at. source_id is the sale or price list
+		// and group_id the group a price list offer was limited to.
+		`ALTER TABLE price_history ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'regular' CHECK (kind IN ('regular', 'sale', 'price_list'))`,
+		`ALTER TABLE price_history ADD COLUMN IF NOT EXISTS source_id INT`,
+		`ALTER TABLE price_history ADD COLUMN IF NOT EXISTS group_id INT`,
+		`ALTER TABLE price_history ADD COLUMN IF NOT EXISTS ends_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_price_history_source ON price_history (kind, source_id)`,
+
+		`CREATE TABLE IF NOT EXISTS promotions (
+			promotion_id    SERIAL PRIMARY KEY,
+			name            TEXT NOT NULL,
+			code            TEXT UNIQUE,
+			type            TEXT NOT NULL CHECK (type IN ('percentage', 'fixed', 'buy_x_get_y')),
+			percent         INT NOT NULL DEFAULT 0,
+			amount          BIGINT,
+			min_spend       BIGINT,
+			currency        CHAR(3),
+			product_id      INT REFERENCES products(product_id) ON DELETE CASCADE,
+			buy_quantity    INT NOT NULL DEFAULT 0,
+			get_quantity    INT NOT NULL DEFAULT 0,
+			per_user_limit  INT NOT NULL DEFAULT 0,
+			starts_at       TIMESTAMP,
+			ends_at         TIMESTAMP,
+			disabled        BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+
+		`ALTER TABLE cartTable ADD COLUMN IF NOT EXISTS coupon_code TEXT`,
+
+		`ALTER TABLE cartTable ALTER COLUMN user_id DROP NOT NULL`,
+		`ALTER TABLE cartTable ADD COLUMN IF NOT EXISTS guest_token TEXT UNIQUE`,
+
+		`CREATE TABLE IF NOT EXISTS cart_promotions (
+			cart_id       INT NOT NULL REFERENCES cartTable(cart_id) ON DELETE CASCADE,
+			promotion_id  INT NOT NULL REFERENCES promotions(promotion_id) ON DELETE CASCADE,
+			discount      BIGINT NOT NULL,
+			PRIMARY KEY (cart_id, promotion_id)
+		)`,
+
+		// A placed order leaves its cart behind, so a guest token is unique
+		// only among active carts.
+		`ALTER TABLE cartTable DROP CONSTRAINT IF EXISTS carttable_guest_token_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_carttable_active_guest ON cartTable (guest_token) WHERE status = 'active'`,
+
+		// A user has one active cart. Carts left over from racing adds are
+		// abandoned, keeping the newest, before the index goes on.
+		`UPDATE cartTable c SET status = 'abandoned'
+			WHERE c.status = 'active' AND c.user_id IS NOT NULL AND EXISTS (
+				SELECT 1 FROM cartTable n WHERE n.user_id = c.user_id AND n.status = 'active' AND n.cart_id > c.cart_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_carttable_active_user ON cartTable (user_id) WHERE status = 'active'`,
+
+		// Redemptions count towards per_user_limit. They are keyed by the
+		// user, or the guest token for guests, and outlive their cart.
+		`CREATE TABLE IF NOT EXISTS promotion_redemptions (
+			redemption_id  SERIAL PRIMARY KEY,
+			promotion_id   INT NOT NULL REFERENCES promotions(promotion_id) ON DELETE CASCADE,
+			cart_id        INT REFERENCES cartTable(cart_id) ON DELETE SET NULL,
+			user_id        INT REFERENCES users(user_id) ON DELETE CASCADE,
+			guest_token    TEXT,
+			discount       BIGINT NOT NULL,
+			currency       CHAR(3) NOT NULL,
+			redeemed_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+			CHECK (user_id IS NOT NULL OR guest_token IS NOT NULL)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_user ON promotion_redemptions (user_id, promotion_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_guest ON promotion_redemptions (guest_token, promotion_id)`,
+
+		// Prices set before history was kept count from when they were set,
+		// or from now when that is not known.
+		`INSERT INTO price_history (product_id, amount, currency)
+			SELECT p.product_id, p.price, p.currency FROM products p
+			WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.product_id = p.product_id AND h.currency = p.currency AND h.kind = 'regular')`,
+
+		`INSERT INTO price_history (product_id, amount, currency, changed_at)
+			SELECT pp.product_id, pp.amount, pp.currency, pp.updated_at FROM product_prices pp
+			WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.product_id = pp.product_id AND h.currency = pp.currency AND h.kind = 'regular')`,
+
+		`INSERT INTO price_history (product_id, amount, currency, changed_at, ends_at, kind, source_id)
+			SELECT s.product_id, s.amount, s.currency, GREATEST(s.starts_at, s.created_at), s.ends_at, 'sale', s.sale_id FROM product_sales s
+			WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.kind = 'sale' AND h.source_id = s.sale_id)`,
+
+		`INSERT INTO price_history (product_id, amount, currency, changed_at, ends_at, kind, source_id, group_id)
+			SELECT i.product_id, i.amount, l.currency, GREATEST(COALESCE(l.starts_at, l.created_at), l.created_at), l.ends_at, 'price_list', l.price_list_id, l.group_id
+			FROM price_list_items i JOIN price_lists l ON l.price_list_id = i.price_list_id
+			WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.kind = 'price_list' AND h.source_id = l.price_list_id AND h.product_id = i.product_id)`,
+
+		`CREATE TABLE IF NOT EXISTS stock_reservations (
+			reservation_id SERIAL PRIMARY KEY,
+			cart_id        INT NOT NULL REFERENCES cartTable(cart_id) ON DELETE CASCADE,
+			product_id     INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			variant_id     INT REFERENCES product_variants(variant_id) ON DELETE CASCADE,
+			quantity       INT NOT NULL CHECK (quantity > 0),
+			expires_at     TIMESTAMPTZ NOT NULL,
+			created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_reservations_line ON stock_reservations (cart_id, product_id, (COALESCE(variant_id, 0)))`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_reservations_item ON stock_reservations (product_id, variant_id, expires_at)`,
+
+		// The ledger is append-only, so it has no foreign keys that could
+		// delete from it and rules turn updates and deletes into no-ops.
+		`CREATE TABLE IF NOT EXISTS inventory_movements (
+			movement_id  BIGSERIAL PRIMARY KEY,
+			product_id   INT NOT NULL,
+			variant_id   INT,
+			type         TEXT NOT NULL CHECK (type IN ('receipt', 'sale', 'adjustment', 'return', 'reservation', 'release')),
+			quantity     INT NOT NULL,
+			reason       TEXT NOT NULL,
+			actor_id     INT,
+			cart_id      INT,
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_movements_item ON inventory_movements (product_id, variant_id, movement_id)`,
+		`CREATE OR REPLACE RULE inventory_movements_no_update AS ON UPDATE TO inventory_movements DO INSTEAD NOTHING`,
+		`CREATE OR REPLACE RULE inventory_movements_no_delete AS ON DELETE TO inventory_movements DO INSTEAD NOTHING`,
+
+		// Stock set before the ledger existed is its opening balance.
+		`INSERT INTO inventory_movements (product_id, type, quantity, reason)
+			SELECT p.product_id, 'adjustment', p.stock, 'opening balance' FROM products p
+			WHERE p.stock <> 0 AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.product_id = p.product_id AND m.variant_id IS NULL)`,
+		`INSERT INTO inventory_movements (product_id, variant_id, type, quantity, reason)
+			SELECT v.product_id, v.variant_id, 'adjustment', v.stock, 'opening balance' FROM product_variants v
+			WHERE v.stock <> 0 AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.variant_id = v.variant_id)`,
+
+		`CREATE TABLE IF NOT EXISTS warehouses (
+			warehouse_id SERIAL PRIMARY KEY,
+			code         TEXT NOT NULL UNIQUE,
+			name         TEXT NOT NULL,
+			latitude     DOUBLE PRECISION,
+			longitude    DOUBLE PRECISION,
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		// products.stock and product_variants.stock stay as the total over
+		// warehouses, which is what the catalog, carts and the ledger read.
+		`CREATE TABLE IF NOT EXISTS warehouse_stock (
+			warehouse_id INT NOT NULL REFERENCES warehouses(warehouse_id),
+			product_id   INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+			variant_id   INT REFERENCES product_variants(variant_id) ON DELETE CASCADE,
+			quantity     INT NOT NULL CHECK (quantity >= 0)
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouse_stock_item ON warehouse_stock (warehouse_id, product_id, (COALESCE(variant_id, 0)))`,
+		`CREATE INDEX IF NOT EXISTS idx_warehouse_stock_product ON warehouse_stock (product_id, variant_id)`,
+		`ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id INT`,
+		`ALTER TABLE inventory_movements DROP CONSTRAINT IF EXISTS inventory_movements_type_check`,
+		`ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_type_check
+			CHECK (type IN ('receipt', 'sale', 'adjustment', 'return', 'transfer', 'reservation', 'release'))`,
+
+		// Stock held before warehouses existed is in the first one.
+		`INSERT INTO warehouses (code, name) SELECT 'MAIN', 'Main warehouse' WHERE NOT EXISTS (SELECT 1 FROM warehouses)`,
+		`INSERT INTO warehouse_stock (warehouse_id, product_id, quantity)
+			SELECT (SELECT MIN(warehouse_id) FROM warehouses), p.product_id, p.stock FROM products p
+			WHERE p.stock > 0 AND NOT EXISTS (SELECT 1 FROM warehouse_stock s WHERE s.product_id = p.product_id AND s.variant_id IS NULL)`,
+		`INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, quantity)
+			SELECT (SELECT MIN(warehouse_id) FROM warehouses), v.product_id, v.variant_id, v.stock FROM product_variants v
+			WHERE v.stock > 0 AND NOT EXISTS (SELECT 1 FROM warehouse_stock s WHERE s.variant_id = v.variant_id)`,
+
+		// Deleted variants stay in the trash, so cart and wishlist lines
+		// pointing at them survive until the purge.
+		`ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+
+		// Restores, per-currency prices and sales are held for approval too.
+		`ALTER TABLE product_change_requests ADD COLUMN IF NOT EXISTS sale JSONB`,
+		`ALTER TABLE product_change_requests DROP CONSTRAINT IF EXISTS product_change_requests_action_check`,
+		`ALTER TABLE product_change_requests ADD CONSTRAINT product_change_requests_action_check
+			CHECK (action IN ('update', 'delete', 'restore', 'price', 'sale'))`,
+		`CREATE INDEX IF NOT EXISTS idx_product_revisions_actor ON product_revisions (actor_id, action, changed_at)`,
+
+		// Variant and price list prices are held for approval too.
+		`ALTER TABLE product_change_requests ADD COLUMN IF NOT EXISTS variant JSONB`,
+		`ALTER TABLE product_change_requests ADD COLUMN IF NOT EXISTS price_list JSONB`,
+		`ALTER TABLE product_change_requests DROP CONSTRAINT IF EXISTS product_change_requests_action_check`,
+		`ALTER TABLE product_change_requests ADD CONSTRAINT product_change_requests_action_check
+			CHECK (action IN ('update', 'delete', 'restore', 'price', 'sale', 'variant', 'price_list'))`,
 	}
 
 	for _, query := range tables {
@@ -93,7 +464,38 @@ func New(cfg *config.Config) (*Postgres, error) {
 		return nil, err
 	}
 
-	return &Postgres{Db: db}, nil
+	return &Postgres{Db: db, currency: cfg.Catalog.Currency, cart: cfg.Cart, approval: cfg.Approval}, nil
+}
+
+func moneyMigration(currency string) string {
+	factor := modules.MinorUnitFactor(currency)
+	return fmt.Sprintf(`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'products' AND column_name = 'currency') THEN
+				ALTER TABLE products ALTER COLUMN price TYPE BIGINT USING price::BIGINT * %[2]d;
+				ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT %[1]s;
+
+				ALTER TABLE product_variants ALTER COLUMN price TYPE BIGINT USING price::BIGINT * %[2]d;
+				ALTER TABLE product_variants ADD COLUMN currency CHAR(3);
+				UPDATE product_variants v SET currency = p.currency FROM products p WHERE p.product_id = v.product_id AND v.price IS NOT NULL;
+
+				ALTER TABLE cartItems ALTER COLUMN price_at_time TYPE BIGINT USING ROUND(price_at_time * %[2]d)::BIGINT;
+				ALTER TABLE cartItems ALTER COLUMN discount TYPE BIGINT USING ROUND(COALESCE(discount, 0) * %[2]d)::BIGINT;
+				ALTER TABLE cartItems ALTER COLUMN discount SET DEFAULT 0;
+				ALTER TABLE cartItems ALTER COLUMN discount SET NOT NULL;
+				ALTER TABLE cartItems ALTER COLUMN subtotal TYPE BIGINT USING ROUND(subtotal * %[2]d)::BIGINT;
+				ALTER TABLE cartItems ADD COLUMN currency CHAR(3) NOT NULL DEFAULT %[1]s;
+				UPDATE cartItems ci SET currency = p.currency FROM products p WHERE p.product_id = ci.product_id;
+			END IF;
+		END $$`, pq.QuoteLiteral(currency), factor)
+}
+
+// legacyPriceMigration rewrites a bare-number price in the product JSON in
+// column of table as a Money object, reading it as whole units of currency.
+func legacyPriceMigration(table string, column string, currency string) string {
+	return fmt.Sprintf(`UPDATE %[1]s
+		SET %[2]s = jsonb_set(%[2]s, '{price}', jsonb_build_object('amount', ROUND((%[2]s->>'price')::NUMERIC * %[4]d)::BIGINT, 'currency', %[3]s))
+		WHERE jsonb_typeof(%[2]s->'price') = 'number'`, table, column, pq.QuoteLiteral(currency), modules.MinorUnitFactor(currency))
 }
 
 func InvalidateProductCache() {
@@ -106,5 +508,28 @@ func InvalidateProductCache() {
 	fmt.Println("Data Erased from cache memory")
 }
 
+// InvalidateProductCacheById drops the cached copies of one product and the
+// listing caches that may include it in every currency, leaving every other
+// key in place.
+func InvalidateProductCacheById(id int) {
+	var keys []string
+
+	patterns := []string{fmt.Sprintf("product:%d:*", id), "products_cache_key:*", "default_products:*", "products:filtered:*"}
+	for _, pattern := range patterns {
+		iter := cache.Rdb.Scan(cache.Ctx, 0, pattern, 100).Iterator()
+		for iter.Next(cache.Ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			fmt.Println("Error Scanning Cache: ", err)
+		}
+	}
 
+	if len(keys) == 0 {
+		return
+	}
 
+	if err := cache.Rdb.Del(cache.Ctx, keys...).Err(); err != nil {
+		fmt.Println("Error Clearing Cache: ", err)
+	}
+}
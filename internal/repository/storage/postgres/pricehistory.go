@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// recordPriceChange appends to price_history when a write changes a
+// product's price. It runs with every product revision, so creates, updates,
+// patches, imports and restores are all covered.
+func recordPriceChange(tx *sql.Tx, before, after *modules.Product, actorId int) error {
+	if after == nil || (before != nil && before.Price == after.Price) {
+		return nil
+	}
+
+	_, err := tx.Exec(`INSERT INTO price_history (product_id, amount, currency, changed_by) VALUES ($1, $2, $3, $4)`,
+		after.ProductId, after.Price.Amount, after.Price.Currency, nullableId(actorId))
+	if err != nil {
+		return fmt.Errorf("error recording price change: %w", err)
+	}
+	return nil
+}
+
+// GetPriceHistory returns the regular price changes of a published product,
+// newest first, optionally only those in currency.
+func (p *Postgres) GetPriceHistory(productId int, currency string) ([]modules.PriceChange, error) {
+	var exists bool
+	if err := p.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM products p WHERE p.product_id = $1 AND `+publicProduct+`)`, productId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: product %d", storage.ErrNotFound, productId)
+	}
+
+	rows, err := p.Db.Query(`
+		SELECT amount, currency, changed_at FROM price_history
+		WHERE product_id = $1 AND kind = 'regular' AND ($2 = '' OR currency = $2)
+		ORDER BY changed_at DESC, price_history_id DESC
+	`, productId, currency)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching price history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []modules.PriceChange{}
+	for rows.Next() {
+		var change modules.PriceChange
+		if err := rows.Scan(&change.Price.Amount, &change.Price.Currency, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+// recordSaleOffer adds a sale to the price history from when it takes
+// effect.
+func recordSaleOffer(tx *sql.Tx, saleId int) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_history (product_id, amount, currency, changed_at, ends_at, kind, source_id)
+		SELECT product_id, amount, currency, GREATEST(starts_at, NOW()), ends_at, 'sale', sale_id
+		FROM product_sales WHERE sale_id = $1
+	`, saleId)
+	if err != nil {
+		return fmt.Errorf("error recording sale price: %w", err)
+	}
+	return nil
+}
+
+// recordPriceListOffers adds the items of a price list to the price history
+// from when they take effect.
+func recordPriceListOffers(tx *sql.Tx, priceListId int) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_history (product_id, amount, currency, changed_at, ends_at, kind, source_id, group_id)
+		SELECT i.product_id, i.amount, l.currency, GREATEST(COALESCE(l.starts_at, NOW()), NOW()), l.ends_at, 'price_list', l.price_list_id, l.group_id
+		FROM price_list_items i
+		JOIN price_lists l ON l.price_list_id = i.price_list_id
+		WHERE l.price_list_id = $1
+	`, priceListId)
+	if err != nil {
+		return fmt.Errorf("error recording price list prices: %w", err)
+	}
+	return nil
+}
+
+// endOffers ends the history entries of a sale or price list that are still
+// running or scheduled, when it is replaced or deleted.
+func endOffers(db execer, kind string, sourceId int) error {
+	_, err := db.Exec(`
+		UPDATE price_history SET ends_at = NOW()
+		WHERE kind = $1 AND source_id = $2 AND (ends_at IS NULL OR ends_at > NOW())
+	`, kind, sourceId)
+	if err != nil {
+		return fmt.Errorf("error ending %s prices: %w", kind, err)
+	}
+	return nil
+}
+
+// loadPriceHistory loads the prices productIds were offered at from 30 days
+// before the oldest loaded offer started, or before now: regular prices
+// until they were replaced, and sale and price list offers, those of the
+// given groups only, until they ended. The expiry moves up to when the
+// oldest of those prices drops out of the last 30 days.
+func (p *Postgres) loadPriceHistory(r *priceResolver, groups []int, productIds []int) error {
+	back := priceHistoryWindow
+	now := time.Now()
+	for _, offers := range r.offers {
+		for _, o := range offers {
+			back = max(back, now.Sub(o.since)+priceHistoryWindow)
+		}
+	}
+
+	rows, err := p.Db.Query(`
+		SELECT price_history_id, product_id, amount, currency, FALSE,
+			EXTRACT(EPOCH FROM changed_at - NOW()), EXTRACT(EPOCH FROM replaced_at - NOW())
+		FROM (
+			SELECT price_history_id, product_id, amount, currency, changed_at,
+				LEAD(changed_at) OVER (PARTITION BY product_id, currency ORDER BY changed_at, price_history_id) AS replaced_at
+			FROM price_history
+			WHERE product_id = ANY($1) AND kind = 'regular'
+		) h
+		WHERE replaced_at IS NULL OR replaced_at > NOW() - $2 * INTERVAL '1 second'
+		UNION ALL
+		SELECT price_history_id, product_id, amount, currency, TRUE,
+			EXTRACT(EPOCH FROM changed_at - NOW()), EXTRACT(EPOCH FROM ends_at - NOW())
+		FROM price_history
+		WHERE product_id = ANY($1) AND (kind = 'sale' OR (kind = 'price_list' AND group_id = ANY($3)))
+			AND changed_at <= NOW() AND (ends_at IS NULL OR (ends_at > changed_at AND ends_at > NOW() - $2 * INTERVAL '1 second'))
+	`, pq.Array(productIds), back.Seconds(), pq.Array(groups))
+	if err != nil {
+		return fmt.Errorf("error fetching price history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var entry pricePeriod
+		var from float64
+		var to sql.NullFloat64
+		if err := rows.Scan(&entry.historyId, &id, &entry.price.Amount, &entry.price.Currency, &entry.offer, &from, &to); err != nil {
+			return err
+		}
+
+		entry.from = now.Add(time.Duration(from * float64(time.Second)))
+		if to.Valid {
+			entry.to = now.Add(time.Duration(to.Float64 * float64(time.Second)))
+			if leaves := entry.to.Add(priceHistoryWindow); leaves.After(now) {
+				r.expireAt(leaves)
+			}
+		}
+		r.history[id] = append(r.history[id], entry)
+	}
+
+	return rows.Err()
+}
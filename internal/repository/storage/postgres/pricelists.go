@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+func (p *Postgres) CreateUserGroup(name string) (modules.UserGroup, error) {
+	group := modules.UserGroup{Name: name, Members: []int{}}
+	err := p.Db.QueryRow(`INSERT INTO user_groups (name) VALUES ($1) RETURNING group_id`, name).Scan(&group.GroupId)
+	if err != nil {
+		if hasPqCode(err, "23505") {
+			return modules.UserGroup{}, fmt.Errorf("%w: user group %q already exists", storage.ErrConflict, name)
+		}
+		return modules.UserGroup{}, fmt.Errorf("failed to create user group: %w", err)
+	}
+	return group, nil
+}
+
+func (p *Postgres) GetUserGroups() ([]modules.UserGroup, error) {
+	rows, err := p.Db.Query(`
+		SELECT g.group_id, g.name, COALESCE(ARRAY_AGG(m.user_id ORDER BY m.user_id) FILTER (WHERE m.user_id IS NOT NULL), '{}')
+		FROM user_groups g
+		LEFT JOIN user_group_members m ON m.group_id = g.group_id
+		GROUP BY g.group_id
+		ORDER BY g.group_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []modules.UserGroup{}
+	for rows.Next() {
+		var group modules.UserGroup
+		var members pq.Int64Array
+		if err := rows.Scan(&group.GroupId, &group.Name, &members); err != nil {
+			return nil, err
+		}
+		group.Members = make([]int, len(members))
+		for i, id := range members {
+			group.Members[i] = int(id)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// AddUserToGroup puts userId in groupId; adding an existing member is a no-op.
+func (p *Postgres) AddUserToGroup(groupId int, userId int) error {
+	_, err := p.Db.Exec(`INSERT INTO user_group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, groupId, userId)
+	if hasPqCode(err, "23503") {
+		return fmt.Errorf("%w: user group %d or user %d", storage.ErrNotFound, groupId, userId)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add user to group: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) RemoveUserFromGroup(groupId int, userId int) error {
+	res, err := p.Db.Exec(`DELETE FROM user_group_members WHERE group_id = $1 AND user_id = $2`, groupId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to remove user from group: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: user %d is not in group %d", storage.ErrNotFound, userId, groupId)
+	}
+	return nil
+}
+
+// CreatePriceList adds a price list. A list pricing any product too far from
+// its regular price is held for approval as a whole.
+func (p *Postgres) CreatePriceList(list modules.PriceList, actorId int) (modules.PriceList, error) {
+	list.PriceListId = 0
+	return p.writePriceList(list, actorId)
+}
+
+// UpdatePriceList replaces a price list, including all of its items, holding
+// it for approval as CreatePriceList does.
+func (p *Postgres) UpdatePriceList(id int, list modules.PriceList, actorId int) (modules.PriceList, error) {
+	list.PriceListId = id
+	return p.writePriceList(list, actorId)
+}
+
+func (p *Postgres) writePriceList(list modules.PriceList, actorId int) (modules.PriceList, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.PriceList{}, err
+	}
+	defer tx.Rollback()
+
+	if err := p.holdPriceListPrices(tx, list, actorId); err != nil {
+		return modules.PriceList{}, commitHeld(tx, err)
+	}
+
+	list, err = savePriceList(tx, list)
+	if err != nil {
+		return modules.PriceList{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.PriceList{}, err
+	}
+
+	InvalidateProductCache()
+	return list, nil
+}
+
+// holdPriceListPrices holds list for approval when any of its items is too
+// far from the product's regular price in the list's currency.
+func (p *Postgres) holdPriceListPrices(tx *sql.Tx, list modules.PriceList, actorId int) error {
+	for _, item := range list.Items {
+		stored, version, err := lockProductPrice(tx, item.ProductId)
+		if err != nil {
+			return err
+		}
+
+		regular, err := p.regularPriceIn(item.ProductId, stored, list.Currency)
+		if err != nil {
+			return err
+		}
+
+		cr := modules.ChangeRequest{ProductId: item.ProductId, BaseVersion: version, Action: "price_list", PriceList: &list}
+		if err := p.holdPriceChange(tx, regular, modules.Money{Amount: item.Amount, Currency: list.Currency}, cr, actorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savePriceList inserts list, or replaces the list with its PriceListId,
+// without checking whether it needs approval.
+func savePriceList(tx *sql.Tx, list modules.PriceList) (modules.PriceList, error) {
+	if list.PriceListId == 0 {
+		err := tx.QueryRow(`
+			INSERT INTO price_lists (name, group_id, currency, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5)
+			RETURNING price_list_id
+		`, list.Name, list.GroupId, list.Currency, list.StartsAt, list.EndsAt).Scan(&list.PriceListId)
+		if err != nil {
+			return modules.PriceList{}, priceListError(err)
+		}
+	} else {
+		res, err := tx.Exec(`
+			UPDATE price_lists SET name = $1, group_id = $2, currency = $3, starts_at = $4, ends_at = $5
+			WHERE price_list_id = $6
+		`, list.Name, list.GroupId, list.Currency, list.StartsAt, list.EndsAt, list.PriceListId)
+		if err != nil {
+			return modules.PriceList{}, priceListError(err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return modules.PriceList{}, fmt.Errorf("%w: price list %d", storage.ErrNotFound, list.PriceListId)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM price_list_items WHERE price_list_id = $1`, list.PriceListId); err != nil {
+			return modules.PriceList{}, fmt.Errorf("failed to replace price list items: %w", err)
+		}
+		if err := endOffers(tx, "price_list", list.PriceListId); err != nil {
+			return modules.PriceList{}, err
+		}
+	}
+
+	if err := insertPriceListItems(tx, list); err != nil {
+		return modules.PriceList{}, err
+	}
+	if err := recordPriceListOffers(tx, list.PriceListId); err != nil {
+		return modules.PriceList{}, err
+	}
+	return list, nil
+}
+
+func insertPriceListItems(tx *sql.Tx, list modules.PriceList) error {
+	for _, item := range list.Items {
+		_, err := tx.Exec(`
+			INSERT INTO price_list_items (price_list_id, product_id, amount) VALUES ($1, $2, $3)
+			ON CONFLICT (price_list_id, product_id) DO UPDATE SET amount = EXCLUDED.amount
+		`, list.PriceListId, item.ProductId, item.Amount)
+		if hasPqCode(err, "23503") {
+			return fmt.Errorf("%w: product %d", storage.ErrNotFound, item.ProductId)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save price list item: %w", err)
+		}
+	}
+	return nil
+}
+
+// priceListError reports a missing user group as ErrNotFound.
+func priceListError(err error) error {
+	if hasPqCode(err, "23503") {
+		return fmt.Errorf("%w: user group", storage.ErrNotFound)
+	}
+	return fmt.Errorf("failed to save price list: %w", err)
+}
+
+func (p *Postgres) GetPriceLists() ([]modules.PriceList, error) {
+	rows, err := p.Db.Query(`
+		SELECT l.price_list_id, l.name, l.group_id, l.currency, l.starts_at, l.ends_at, i.product_id, i.amount
+		FROM price_lists l
+		LEFT JOIN price_list_items i ON i.price_list_id = l.price_list_id
+		ORDER BY l.price_list_id, i.product_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching price lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []modules.PriceList{}
+	for rows.Next() {
+		var list modules.PriceList
+		var productId sql.NullInt64
+		var amount sql.NullInt64
+		if err := rows.Scan(&list.PriceListId, &list.Name, &list.GroupId, &list.Currency, &list.StartsAt, &list.EndsAt, &productId, &amount); err != nil {
+			return nil, err
+		}
+
+		if n := len(lists); n == 0 || lists[n-1].PriceListId != list.PriceListId {
+			list.Items = []modules.PriceListItem{}
+			lists = append(lists, list)
+		}
+		if productId.Valid {
+			last := &lists[len(lists)-1]
+			last.Items = append(last.Items, modules.PriceListItem{ProductId: int(productId.Int64), Amount: amount.Int64})
+		}
+	}
+
+	return lists, rows.Err()
+}
+
+func (p *Postgres) DeletePriceList(id int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM price_lists WHERE price_list_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete price list: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: price list %d", storage.ErrNotFound, id)
+	}
+
+	if err := endOffers(tx, "price_list", id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidateProductCache()
+	return nil
+}
+
+// CreateSalePrice schedules a sale price for a product. A sale without a
+// currency is in the product's currency. A sale too far below the regular
+// price is held for approval.
+func (p *Postgres) CreateSalePrice(productId int, sale modules.SalePrice, actorId int) (modules.SalePrice, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.SalePrice{}, err
+	}
+	defer tx.Rollback()
+
+	stored, version, err := lockProductPrice(tx, productId)
+	if err != nil {
+		return modules.SalePrice{}, err
+	}
+
+	sale.ProductId = productId
+	if sale.Price.Currency == "" {
+		sale.Price.Currency = stored.Currency
+	}
+
+	regular, err := p.regularPriceIn(productId, stored, sale.Price.Currency)
+	if err != nil {
+		return modules.SalePrice{}, err
+	}
+
+	cr := modules.ChangeRequest{ProductId: productId, BaseVersion: version, Action: "sale", Sale: &sale}
+	if err := p.holdPriceChange(tx, regular, sale.Price, cr, actorId); err != nil {
+		return modules.SalePrice{}, commitHeld(tx, err)
+	}
+
+	sale, err = insertSalePrice(tx, productId, sale)
+	if err != nil {
+		return modules.SalePrice{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.SalePrice{}, err
+	}
+
+	InvalidateProductCacheById(productId)
+	return sale, nil
+}
+
+func insertSalePrice(tx *sql.Tx, productId int, sale modules.SalePrice) (modules.SalePrice, error) {
+	sale.ProductId = productId
+	err := tx.QueryRow(`
+		INSERT INTO product_sales (product_id, amount, currency, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING sale_id
+	`, productId, sale.Price.Amount, sale.Price.Currency, sale.StartsAt, sale.EndsAt).Scan(&sale.SaleId)
+	if err != nil {
+		return modules.SalePrice{}, fmt.Errorf("failed to create sale price: %w", err)
+	}
+
+	if err := recordSaleOffer(tx, sale.SaleId); err != nil {
+		return modules.SalePrice{}, err
+	}
+
+	return sale, nil
+}
+
+// GetSalePrices lists a product's sales that have not ended.
+func (p *Postgres) GetSalePrices(productId int) ([]modules.SalePrice, error) {
+	rows, err := p.Db.Query(`
+		SELECT sale_id, product_id, amount, currency, starts_at, ends_at
+		FROM product_sales
+		WHERE product_id = $1 AND (ends_at IS NULL OR ends_at > NOW())
+		ORDER BY starts_at
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sale prices: %w", err)
+	}
+	defer rows.Close()
+
+	sales := []modules.SalePrice{}
+	for rows.Next() {
+		var sale modules.SalePrice
+		if err := rows.Scan(&sale.SaleId, &sale.ProductId, &sale.Price.Amount, &sale.Price.Currency, &sale.StartsAt, &sale.EndsAt); err != nil {
+			return nil, err
+		}
+		sales = append(sales, sale)
+	}
+
+	return sales, rows.Err()
+}
+
+func (p *Postgres) DeleteSalePrice(productId int, saleId int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM product_sales WHERE sale_id = $1 AND product_id = $2`, saleId, productId)
+	if err != nil {
+		return fmt.Errorf("failed to delete sale price: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: sale %d of product %d", storage.ErrNotFound, saleId, productId)
+	}
+
+	if err := endOffers(tx, "sale", saleId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidateProductCacheById(productId)
+	return nil
+}
+
+// hasPqCode reports whether err is a Postgres error with the given
+// SQLSTATE, such as 23505 for unique or 23503 for foreign key violations.
+func hasPqCode(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
+}
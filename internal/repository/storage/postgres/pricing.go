@@ -0,0 +1,595 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// productCacheTTL is how long product reads stay cached when no price
+// change is scheduled before then.
+const productCacheTTL = 7 * 24 * time.Hour
+
+// priceHistoryWindow is how far back lowest_price_30d looks.
+const priceHistoryWindow = 30 * 24 * time.Hour
+
+// priceResolver is the single place effective prices are worked out. The
+// regular price is a product's explicit price in the requested currency, or
+// its stored price converted with the exchange-rate table (using the inverse
+// of a rate when only the opposite pair is set). Active sale prices and the
+// price lists of the user's groups then apply when they are lower.
+type priceResolver struct {
+	currency string
+	explicit map[int]int64
+	rates    map[string]float64
+	offers   map[int][]priceOffer
+	history  map[int][]pricePeriod
+
+	// expires is when the next loaded sale or price list starts or ends, or
+	// a price leaves the 30-day window; zero when none is due.
+	expires time.Time
+}
+
+// priceOffer is an active sale or price list price. historyId is its entry
+// in price_history and since when it took effect.
+type priceOffer struct {
+	price     modules.Money
+	historyId int
+	since     time.Time
+}
+
+// pricePeriod is a price_history entry: a regular price or an offer in force
+// from from until to, which is zero while it still is.
+type pricePeriod struct {
+	historyId int
+	price     modules.Money
+	offer     bool
+	from      time.Time
+	to        time.Time
+}
+
+// newPriceResolver loads what is needed to price productIds for pc.
+func (p *Postgres) newPriceResolver(pc modules.PriceContext, productIds []int) (*priceResolver, error) {
+	r := &priceResolver{currency: pc.Currency, explicit: map[int]int64{}, rates: map[string]float64{}, offers: map[int][]priceOffer{}, history: map[int][]pricePeriod{}}
+	if len(productIds) == 0 {
+		return r, nil
+	}
+
+	groups, err := p.userGroupIds(pc.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.loadOffers(r, groups, productIds); err != nil {
+		return nil, err
+	}
+	if err := p.loadPriceHistory(r, groups, productIds); err != nil {
+		return nil, err
+	}
+
+	if r.currency == "" {
+		return r, nil
+	}
+
+	rows, err := p.Db.Query(`SELECT product_id, amount FROM product_prices WHERE currency = $1 AND product_id = ANY($2)`, r.currency, pq.Array(productIds))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching product prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var amount int64
+		if err := rows.Scan(&id, &amount); err != nil {
+			return nil, err
+		}
+		r.explicit[id] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return r, p.loadRates(r)
+}
+
+// loadRates loads the rates converting into the resolver's currency.
+func (p *Postgres) loadRates(r *priceResolver) error {
+	// Direct rates sort last so they overwrite inverted ones.
+	rateRows, err := p.Db.Query(`
+		SELECT to_currency, 1 / rate, 0 FROM exchange_rates WHERE from_currency = $1
+		UNION ALL
+		SELECT from_currency, rate, 1 FROM exchange_rates WHERE to_currency = $1
+		ORDER BY 3
+	`, r.currency)
+	if err != nil {
+		return fmt.Errorf("error fetching exchange rates: %w", err)
+	}
+	defer rateRows.Close()
+
+	for rateRows.Next() {
+		var from string
+		var rate float64
+		var direct int
+		if err := rateRows.Scan(&from, &rate, &direct); err != nil {
+			return err
+		}
+		r.rates[from] = rate
+	}
+
+	return rateRows.Err()
+}
+
+// loadOffers loads the sale prices of productIds and the prices on the
+// price lists of groups that have not ended yet, with their price_history
+// entries. Those that have not started only bring the resolver's expiry
+// forward.
+func (p *Postgres) loadOffers(r *priceResolver, groups []int, productIds []int) error {
+	rows, err := p.Db.Query(`
+		SELECT s.product_id, s.amount, s.currency, s.starts_at > NOW(),
+			EXTRACT(EPOCH FROM (CASE WHEN s.starts_at > NOW() THEN s.starts_at ELSE s.ends_at END) - NOW()),
+			h.price_history_id, EXTRACT(EPOCH FROM COALESCE(h.changed_at, s.starts_at) - NOW())
+		FROM product_sales s
+		LEFT JOIN LATERAL (
+			SELECT price_history_id, changed_at FROM price_history
+			WHERE kind = 'sale' AND source_id = s.sale_id
+			ORDER BY price_history_id DESC LIMIT 1
+		) h ON TRUE
+		WHERE s.product_id = ANY($1) AND (s.ends_at IS NULL OR s.ends_at > NOW())
+		UNION ALL
+		SELECT i.product_id, i.amount, l.currency, COALESCE(l.starts_at > NOW(), FALSE),
+			EXTRACT(EPOCH FROM (CASE WHEN l.starts_at > NOW() THEN l.starts_at ELSE l.ends_at END) - NOW()),
+			h.price_history_id, EXTRACT(EPOCH FROM COALESCE(h.changed_at, l.starts_at, l.created_at) - NOW())
+		FROM price_list_items i
+		JOIN price_lists l ON l.price_list_id = i.price_list_id
+		LEFT JOIN LATERAL (
+			SELECT price_history_id, changed_at FROM price_history
+			WHERE kind = 'price_list' AND source_id = l.price_list_id AND product_id = i.product_id
+			ORDER BY price_history_id DESC LIMIT 1
+		) h ON TRUE
+		WHERE i.product_id = ANY($1) AND l.group_id = ANY($2) AND (l.ends_at IS NULL OR l.ends_at > NOW())
+	`, pq.Array(productIds), pq.Array(groups))
+	if err != nil {
+		return fmt.Errorf("error fetching sale prices: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var id int
+		var offer priceOffer
+		var pending bool
+		var changesIn sql.NullFloat64
+		var historyId sql.NullInt64
+		var since float64
+		if err := rows.Scan(&id, &offer.price.Amount, &offer.price.Currency, &pending, &changesIn, &historyId, &since); err != nil {
+			return err
+		}
+
+		if changesIn.Valid {
+			r.expireAt(now.Add(time.Duration(changesIn.Float64 * float64(time.Second))))
+		}
+		if !pending {
+			offer.historyId = int(historyId.Int64)
+			offer.since = now.Add(time.Duration(since * float64(time.Second)))
+			r.offers[id] = append(r.offers[id], offer)
+		}
+	}
+
+	return rows.Err()
+}
+
+// expireAt brings the resolver's expiry forward to at.
+func (r *priceResolver) expireAt(at time.Time) {
+	if r.expires.IsZero() || at.Before(r.expires) {
+		r.expires = at
+	}
+}
+
+// userGroupIds returns the groups userId belongs to, in id order.
+func (p *Postgres) userGroupIds(userId int) ([]int, error) {
+	if userId == 0 {
+		return nil, nil
+	}
+
+	rows, err := p.Db.Query(`SELECT group_id FROM user_group_members WHERE user_id = $1 ORDER BY group_id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		groups = append(groups, id)
+	}
+
+	return groups, rows.Err()
+}
+
+// convert returns m in the resolver's currency.
+func (r *priceResolver) convert(m modules.Money) (modules.Money, error) {
+	if r.currency == "" || m.Currency == r.currency {
+		return m, nil
+	}
+
+	rate, ok := r.rates[m.Currency]
+	if !ok {
+		return modules.Money{}, fmt.Errorf("%w: no exchange rate from %s to %s", storage.ErrUnsupportedCurrency, m.Currency, r.currency)
+	}
+
+	units := float64(m.Amount) / float64(modules.MinorUnitFactor(m.Currency)) * rate
+	return modules.Money{
+		Amount:   int64(math.Round(units * float64(modules.MinorUnitFactor(r.currency)))),
+		Currency: r.currency,
+	}, nil
+}
+
+// regular returns the price of productId before sales and price lists.
+// variant is true when stored is a variant's own price, which explicit
+// product prices do not replace.
+func (r *priceResolver) regular(productId int, stored modules.Money, variant bool) (modules.Money, error) {
+	if amount, ok := r.explicit[productId]; ok && !variant {
+		return modules.Money{Amount: amount, Currency: r.currency}, nil
+	}
+	return r.convert(stored)
+}
+
+// price returns the effective price of productId and its regular price.
+// Sales and price lists are set per product, so they do not apply to a
+// variant's own price. An offer in another currency is converted when a
+// currency was requested and skipped when it cannot be.
+func (r *priceResolver) price(productId int, stored modules.Money, variant bool) (modules.Money, modules.Money, error) {
+	regular, err := r.regular(productId, stored, variant)
+	if err != nil || variant {
+		return regular, regular, err
+	}
+
+	effective, _ := r.bestOffer(productId, regular)
+	return effective, regular, nil
+}
+
+// bestOffer returns the lowest of regular and the offers on productId, and
+// the offer it came from, or nil when no offer undercuts regular.
+func (r *priceResolver) bestOffer(productId int, regular modules.Money) (modules.Money, *priceOffer) {
+	effective := regular
+	var best *priceOffer
+	for i, offer := range r.offers[productId] {
+		price := offer.price
+		if price.Currency != regular.Currency {
+			if r.currency == "" {
+				continue
+			}
+			var err error
+			if price, err = r.convert(price); err != nil {
+				continue
+			}
+		}
+		if price.Amount < effective.Amount {
+			effective, best = price, &r.offers[productId][i]
+		}
+	}
+	return effective, best
+}
+
+// lowestPrice returns the lowest price productId was offered at in the 30
+// days before end: its regular price, from the history of its explicit
+// price in the requested currency if it has one, otherwise converted from
+// the history of its stored price, and the offers loaded for the user.
+func (r *priceResolver) lowestPrice(productId int, stored modules.Money, end time.Time) *modules.Money {
+	start := end.Add(-priceHistoryWindow)
+
+	regularCurrency := stored.Currency
+	if _, ok := r.explicit[productId]; ok && r.currency != "" {
+		regularCurrency = r.currency
+	}
+
+	var lowest *modules.Money
+	for _, period := range r.history[productId] {
+		if !period.from.Before(end) || (!period.to.IsZero() && !period.to.After(start)) {
+			continue
+		}
+		if period.offer && r.currency == "" && period.price.Currency != stored.Currency {
+			continue
+		}
+		if !period.offer && period.price.Currency != regularCurrency {
+			continue
+		}
+
+		price, err := r.convert(period.price)
+		if err != nil {
+			continue
+		}
+		if lowest == nil || price.Amount < lowest.Amount {
+			lowest = &price
+		}
+	}
+	return lowest
+}
+
+// reductionStart returns when the offer that brings productId below regular
+// took effect, or now when none does.
+func (r *priceResolver) reductionStart(productId int, regular modules.Money) time.Time {
+	_, offer := r.bestOffer(productId, regular)
+	if offer == nil {
+		return time.Now()
+	}
+	for _, period := range r.history[productId] {
+		if period.historyId == offer.historyId {
+			return period.from
+		}
+	}
+	return offer.since
+}
+
+// apply reprices product and its variants in place.
+func (r *priceResolver) apply(product *modules.Product) error {
+	stored := product.Price
+
+	price, regular, err := r.price(product.ProductId, stored, false)
+	if err != nil {
+		return err
+	}
+	product.Price = price
+	if price.Amount < regular.Amount {
+		product.RegularPrice = &regular
+	}
+	product.LowestPrice30d = r.lowestPrice(product.ProductId, stored, r.reductionStart(product.ProductId, regular))
+
+	for i, v := range product.Variants {
+		if v.Price == nil {
+			continue
+		}
+		price, _, err := r.price(product.ProductId, *v.Price, true)
+		if err != nil {
+			return err
+		}
+		product.Variants[i].Price = &price
+	}
+
+	return nil
+}
+
+// cacheTTL returns how long prices from r may be cached.
+func (r *priceResolver) cacheTTL() time.Duration {
+	if r.expires.IsZero() {
+		return productCacheTTL
+	}
+	// Redis treats a zero TTL as no expiry.
+	return max(min(time.Until(r.expires), productCacheTTL), time.Second)
+}
+
+// priceProducts reprices products for pc and returns how long the result
+// may be cached.
+func (p *Postgres) priceProducts(products []modules.Product, pc modules.PriceContext) (time.Duration, error) {
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ProductId
+	}
+
+	resolver, err := p.newPriceResolver(pc, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range products {
+		if err := resolver.apply(&products[i]); err != nil {
+			return 0, err
+		}
+	}
+	return resolver.cacheTTL(), nil
+}
+
+// priceProduct reprices a single product for pc and returns how long the
+// result may be cached.
+func (p *Postgres) priceProduct(product *modules.Product, pc modules.PriceContext) (time.Duration, error) {
+	resolver, err := p.newPriceResolver(pc, []int{product.ProductId})
+	if err != nil {
+		return 0, err
+	}
+	if err := resolver.apply(product); err != nil {
+		return 0, err
+	}
+	return resolver.cacheTTL(), nil
+}
+
+// priceCacheKey appends what a cached product read was priced for: the
+// currency and, for users in groups, the group ids.
+func (p *Postgres) priceCacheKey(key string, pc modules.PriceContext) (string, error) {
+	if pc.Currency == "" {
+		key += ":base"
+	} else {
+		key += ":" + pc.Currency
+	}
+
+	groups, err := p.userGroupIds(pc.UserId)
+	if err != nil {
+		return "", err
+	}
+	if len(groups) > 0 {
+		ids := make([]string, len(groups))
+		for i, id := range groups {
+			ids[i] = strconv.Itoa(id)
+		}
+		key += ":groups=" + strings.Join(ids, ",")
+	}
+
+	return key, nil
+}
+
+func (p *Postgres) GetProductPrices(productId int) ([]modules.Money, error) {
+	rows, err := p.Db.Query(`SELECT amount, currency FROM product_prices WHERE product_id = $1 ORDER BY currency`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching product prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []modules.Money{}
+	for rows.Next() {
+		var price modules.Money
+		if err := rows.Scan(&price.Amount, &price.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// SetProductPriceIn sets the explicit price of a product in price.Currency,
+// replacing any converted price for that currency. A price too far from the
+// current one in that currency is held for approval.
+func (p *Postgres) SetProductPriceIn(productId int, price modules.Money, actorId int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, version, err := lockProductPrice(tx, productId)
+	if err != nil {
+		return err
+	}
+
+	current, err := p.regularPriceIn(productId, stored, price.Currency)
+	if err != nil {
+		return err
+	}
+
+	cr := modules.ChangeRequest{ProductId: productId, BaseVersion: version, Action: "price", Proposed: modules.Product{ProductId: productId, Price: price}}
+	if err := p.holdPriceChange(tx, current, price, cr, actorId); err != nil {
+		return commitHeld(tx, err)
+	}
+
+	if err := setPriceIn(tx, productId, price, actorId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidateProductCacheById(productId)
+	return nil
+}
+
+func setPriceIn(tx *sql.Tx, productId int, price modules.Money, actorId int) error {
+	var changed bool
+	err := tx.QueryRow(`
+		WITH old AS (SELECT amount FROM product_prices WHERE product_id = $1 AND currency = $2)
+		INSERT INTO product_prices (product_id, currency, amount) VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, currency) DO UPDATE SET amount = EXCLUDED.amount, updated_at = NOW()
+		RETURNING NOT EXISTS (SELECT 1 FROM old WHERE old.amount = $3)
+	`, productId, price.Currency, price.Amount).Scan(&changed)
+	if err != nil {
+		return fmt.Errorf("failed to set product price: %w", err)
+	}
+
+	if changed {
+		return recordPriceChange(tx, nil, &modules.Product{ProductId: productId, Price: price}, actorId)
+	}
+	return nil
+}
+
+// lockProductPrice locks a live product and returns its stored price and
+// version.
+func lockProductPrice(tx *sql.Tx, productId int) (modules.Money, int, error) {
+	var price modules.Money
+	var version int
+	err := tx.QueryRow(`SELECT price, currency, version FROM products WHERE product_id = $1 AND deleted_at IS NULL FOR UPDATE`, productId).Scan(&price.Amount, &price.Currency, &version)
+	if err == sql.ErrNoRows {
+		return modules.Money{}, 0, fmt.Errorf("%w: product %d", storage.ErrNotFound, productId)
+	}
+	if err != nil {
+		return modules.Money{}, 0, fmt.Errorf("error fetching product: %v", err)
+	}
+
+	return price, version, nil
+}
+
+// regularPriceIn returns a product's regular price in currency, before
+// sales and price lists. It is zero when the product has no explicit price
+// in currency and no exchange rate converts its stored price.
+func (p *Postgres) regularPriceIn(productId int, stored modules.Money, currency string) (modules.Money, error) {
+	r, err := p.newPriceResolver(modules.PriceContext{Currency: currency}, []int{productId})
+	if err != nil {
+		return modules.Money{}, err
+	}
+
+	price, err := r.regular(productId, stored, false)
+	if errors.Is(err, storage.ErrUnsupportedCurrency) {
+		return modules.Money{Currency: currency}, nil
+	}
+	return price, err
+}
+
+func (p *Postgres) DeleteProductPriceIn(productId int, currency string) error {
+	res, err := p.Db.Exec(`DELETE FROM product_prices WHERE product_id = $1 AND currency = $2`, productId, currency)
+	if err != nil {
+		return fmt.Errorf("failed to delete product price: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: product %d has no %s price", storage.ErrNotFound, productId, currency)
+	}
+
+	InvalidateProductCacheById(productId)
+	return nil
+}
+
+func (p *Postgres) GetExchangeRates() ([]modules.ExchangeRate, error) {
+	rows, err := p.Db.Query(`SELECT from_currency, to_currency, rate, updated_at FROM exchange_rates ORDER BY from_currency, to_currency`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []modules.ExchangeRate{}
+	for rows.Next() {
+		var rate modules.ExchangeRate
+		if err := rows.Scan(&rate.From, &rate.To, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+func (p *Postgres) SetExchangeRate(from string, to string, rate float64) (modules.ExchangeRate, error) {
+	saved := modules.ExchangeRate{}
+	err := p.Db.QueryRow(`
+		INSERT INTO exchange_rates (from_currency, to_currency, rate) VALUES ($1, $2, $3)
+		ON CONFLICT (from_currency, to_currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = NOW()
+		RETURNING from_currency, to_currency, rate, updated_at
+	`, strings.ToUpper(from), strings.ToUpper(to), rate).Scan(&saved.From, &saved.To, &saved.Rate, &saved.UpdatedAt)
+	if err != nil {
+		return modules.ExchangeRate{}, fmt.Errorf("failed to set exchange rate: %w", err)
+	}
+
+	// Converted prices in every cached listing may have changed.
+	InvalidateProductCache()
+	return saved, nil
+}
+
+func (p *Postgres) DeleteExchangeRate(from string, to string) error {
+	res, err := p.Db.Exec(`DELETE FROM exchange_rates WHERE from_currency = $1 AND to_currency = $2`, strings.ToUpper(from), strings.ToUpper(to))
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: no exchange rate from %s to %s", storage.ErrNotFound, from, to)
+	}
+
+	InvalidateProductCache()
+	return nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
+
+func TestLowestPriceBeforeReduction(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	eur := func(amount int64) modules.Money { return modules.Money{Amount: amount, Currency: "EUR"} }
+
+	tests := []struct {
+		name    string
+		offers  []priceOffer
+		history []pricePeriod
+		want    int64
+	}{
+		{
+			name: "no reduction uses the last 30 days",
+			history: []pricePeriod{
+				{historyId: 1, price: eur(1200), from: now.Add(-60 * day), to: now.Add(-10 * day)},
+				{historyId: 2, price: eur(1000), from: now.Add(-10 * day)},
+			},
+			want: 1000,
+		},
+		{
+			name:   "sale is not its own reference price",
+			offers: []priceOffer{{price: eur(700), historyId: 3}},
+			history: []pricePeriod{
+				{historyId: 1, price: eur(1000), from: now.Add(-90 * day)},
+				{historyId: 3, price: eur(700), offer: true, from: now.Add(-5 * day)},
+			},
+			want: 1000,
+		},
+		{
+			name:   "earlier sale within 30 days before the reduction counts",
+			offers: []priceOffer{{price: eur(700), historyId: 4}},
+			history: []pricePeriod{
+				{historyId: 1, price: eur(1000), from: now.Add(-90 * day)},
+				{historyId: 3, price: eur(800), offer: true, from: now.Add(-30 * day), to: now.Add(-20 * day)},
+				{historyId: 4, price: eur(700), offer: true, from: now.Add(-5 * day)},
+			},
+			want: 800,
+		},
+		{
+			name:   "window ends when the reduction started, not now",
+			offers: []priceOffer{{price: eur(700), historyId: 4}},
+			history: []pricePeriod{
+				{historyId: 1, price: eur(900), from: now.Add(-200 * day), to: now.Add(-100 * day)},
+				{historyId: 2, price: eur(1000), from: now.Add(-100 * day)},
+				{historyId: 4, price: eur(700), offer: true, from: now.Add(-80 * day)},
+			},
+			want: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &priceResolver{offers: map[int][]priceOffer{1: tt.offers}, history: map[int][]pricePeriod{1: tt.history}}
+			regular := eur(1000)
+
+			got := r.lowestPrice(1, regular, r.reductionStart(1, regular))
+			if got == nil || got.Amount != tt.want {
+				t.Errorf("lowestPrice = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
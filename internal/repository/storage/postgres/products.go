@@ -3,34 +3,123 @@ package postgres
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/nkchakradhari780/catalogServices/internal/cache"
 	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
 )
 
-func (p *Postgres) CreateProduct(name string, price int, stock int, categoryId string, quantity int, Brand string, Images []string) (int, error) {
-	stmt, err := p.Db.Prepare("INSERT INTO products (name, price, stock, category_id, quantity, brand, images) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING product_id")
+// productColumns lists the products columns in the order scanProduct expects.
+// Queries alias the products table as p.
+const productColumns = `p.product_id, p.name, p.price, p.currency, p.stock, p.category_id, p.quantity, p.brand, p.images, p.attributes, p.version, p.deleted_at, p.status, p.publish_at,
+	COALESCE(p.sku, ''), COALESCE(p.external_id, '')`
+
+// publicProduct restricts public reads to live, published products.
+const publicProduct = `p.deleted_at IS NULL AND p.status = 'published'`
+
+// productWriteColumns are the columns a full create or update writes, in the
+// order productWriteValues returns them.
+const productWriteColumns = `name, price, currency, stock, category_id, quantity, brand, images, attributes, status, publish_at, sku, external_id`
+
+func (p *Postgres) productWriteValues(product modules.Product) []any {
+	return []any{product.Name, product.Price.Amount, p.currencyOf(product.Price), product.Stock, product.CategoryID, product.Quantity, product.Brand,
+		jsonb{nonNilImages(product.Images)}, jsonb{nonNilAttributes(product.Attributes)}, productStatus(product.Status), product.PublishAt,
+		nullableString(product.SKU), nullableString(product.ExternalId)}
+}
+
+// placeholders returns "$start, ..., $start+n-1".
+func placeholders(start int, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(params, ", ")
+}
+
+// currencyOf returns the currency of m, or the default for prices given
+// without one.
+func (p *Postgres) currencyOf(m modules.Money) string {
+	if m.Currency == "" {
+		return p.currency
+	}
+	return m.Currency
+}
+
+// nonNilImages keeps a product without images stored as [] rather than null.
+func nonNilImages(images []modules.ProductImage) []modules.ProductImage {
+	if images == nil {
+		return []modules.ProductImage{}
+	}
+	return images
+}
+
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row rowScanner, extra ...any) (modules.Product, error) {
+	var product modules.Product
+	dest := []any{&product.ProductId, &product.Name, &product.Price.Amount, &product.Price.Currency, &product.Stock, &product.CategoryID, &product.Quantity, &product.Brand, jsonb{&product.Images}, jsonb{&product.Attributes}, &product.Version, &product.DeletedAt, &product.Status, &product.PublishAt, &product.SKU, &product.ExternalId}
+	err := row.Scan(append(dest, extra...)...)
+	return product, err
+}
+
+func (p *Postgres) CreateProduct(product modules.Product, actorId int) (int, error) {
+	tx, err := p.Db.Begin()
 	if err != nil {
 		return 0, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	var lastId int
-	err = stmt.QueryRow(name, price, stock, categoryId, quantity, Brand, pq.Array(Images)).Scan(&lastId)
+	created, err := p.insertProduct(tx, product, actorId)
 	if err != nil {
 		return 0, err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	InvalidateProductCache()
 
-	return int(lastId), nil
+	return created.ProductId, nil
+}
+
+func (p *Postgres) insertProduct(tx *sql.Tx, product modules.Product, actorId int) (modules.Product, error) {
+	if err := p.validateAttributes(product.CategoryID, product.Attributes); err != nil {
+		return modules.Product{}, err
+	}
+
+	values := p.productWriteValues(product)
+	created, err := scanProduct(tx.QueryRow("INSERT INTO products AS p ("+productWriteColumns+") VALUES ("+placeholders(1, len(values))+") RETURNING "+productColumns, values...))
+	if err != nil {
+		return modules.Product{}, err
+	}
+
+	if err := recordRevision(tx, "create", nil, &created, actorId); err != nil {
+		return modules.Product{}, err
+	}
+
+	return created, nil
 }
 
-func (p *Postgres) GetProductById(id int) (modules.Product, error) {
-	cacheKey := fmt.Sprintf("product:%d", id)
+func (p *Postgres) GetProductById(id int, pc modules.PriceContext) (modules.Product, error) {
+	cacheKey, err := p.priceCacheKey(fmt.Sprintf("product:%d", id), pc)
+	if err != nil {
+		return modules.Product{}, err
+	}
 
 	if cached, err := cache.Rdb.Get(cache.Ctx, cacheKey).Result(); err == nil {
 		var product modules.Product
@@ -40,32 +129,43 @@ func (p *Postgres) GetProductById(id int) (modules.Product, error) {
 		}
 	}
 
-	stmt, err := p.Db.Prepare("SELECT * FROM products WHERE id = $1")
+	stmt, err := p.Db.Prepare("SELECT " + productColumns + " FROM products p WHERE p.product_id = $1 AND " + publicProduct)
 	if err != nil {
 		return modules.Product{}, err
 	}
 	defer stmt.Close()
 
-	var product modules.Product
-
-	err = stmt.QueryRow(id).Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Brand, pq.Array(&product.Images))
+	product, err := scanProduct(stmt.QueryRow(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return modules.Product{}, fmt.Errorf("product with id %d not found", id)
+			return modules.Product{}, fmt.Errorf("%w: product %d", storage.ErrNotFound, id)
 		}
 		return modules.Product{}, fmt.Errorf("error fetching product: %v", err)
 	}
 
+	product.Variants, err = p.GetVariantsByProductId(id)
+	if err != nil {
+		return modules.Product{}, err
+	}
+
+	ttl, err := p.priceProduct(&product, pc)
+	if err != nil {
+		return modules.Product{}, err
+	}
+
 	data, _ := json.Marshal(product)
-	cache.Rdb.Set(cache.Ctx, cacheKey, data, 7*24*time.Hour)
+	cache.Rdb.Set(cache.Ctx, cacheKey, data, ttl)
 
 	fmt.Println("Cache missed Fetching data from DB.")
 
 	return product, nil
 }
 
-func (p *Postgres) GetProducts() ([]modules.Product, error) {
-	cacheKey := "products_cache_key"
+func (p *Postgres) GetProducts(pc modules.PriceContext) ([]modules.Product, error) {
+	cacheKey, err := p.priceCacheKey("products_cache_key", pc)
+	if err != nil {
+		return nil, err
+	}
 
 	if val, err := cache.Rdb.Get(cache.Ctx, cacheKey).Result(); err == nil {
 		var products []modules.Product
@@ -75,7 +175,7 @@ func (p *Postgres) GetProducts() ([]modules.Product, error) {
 		}
 	}
 
-	stmt, err := p.Db.Prepare("SELECT * FROM products")
+	stmt, err := p.Db.Prepare("SELECT " + productColumns + " FROM products p WHERE " + publicProduct)
 	if err != nil {
 		return nil, err
 	}
@@ -92,24 +192,31 @@ func (p *Postgres) GetProducts() ([]modules.Product, error) {
 	var products []modules.Product
 
 	for rows.Next() {
-		var product modules.Product
-		err := rows.Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Quantity, &product.Brand, pq.Array(&product.Images))
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, err
 		}
 		products = append(products, product)
 	}
 
+	ttl, err := p.priceProducts(products, pc)
+	if err != nil {
+		return nil, err
+	}
+
 	data, _ := json.Marshal(products)
-	cache.Rdb.Set(cache.Ctx, cacheKey, data, 7*24*time.Hour)
+	cache.Rdb.Set(cache.Ctx, cacheKey, data, ttl)
 
 	fmt.Println(" Cache missed data fetched from DB")
 
 	return products, nil
 }
 
-func (p *Postgres) GetDefaultProducts() ([]modules.Product, error) {
-	cacheKey := "default_products"
+func (p *Postgres) GetDefaultProducts(pc modules.PriceContext) ([]modules.Product, error) {
+	cacheKey, err := p.priceCacheKey("default_products", pc)
+	if err != nil {
+		return nil, err
+	}
 
 	cached, err := cache.Rdb.Get(cache.Ctx, cacheKey).Result()
 	if err == nil {
@@ -120,8 +227,9 @@ func (p *Postgres) GetDefaultProducts() ([]modules.Product, error) {
 		}
 	}
 
-	stms, err := p.Db.Prepare(`SELECT product_id, name, price, stock, category_id, quantity, brand, images
-					FROM products
+	stms, err := p.Db.Prepare(`SELECT ` + productColumns + `
+					FROM products p
+					WHERE ` + publicProduct + `
 					ORDER BY RANDOM()
 					LIMIT 50;
 				`)
@@ -140,26 +248,40 @@ func (p *Postgres) GetDefaultProducts() ([]modules.Product, error) {
 	var products []modules.Product
 
 	for rows.Next() {
-		var product modules.Product
-		err := rows.Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Quantity, &product.Brand, pq.Array(&product.Images))
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, err
 		}
 		products = append(products, product)
 	}
 
+	ttl, err := p.priceProducts(products, pc)
+	if err != nil {
+		return nil, err
+	}
+
 	data, _ := json.Marshal(products)
-	cache.Rdb.Set(cache.Ctx, cacheKey, data, 7*24*time.Hour)
+	cache.Rdb.Set(cache.Ctx, cacheKey, data, ttl)
 
 	fmt.Println("Cache missed fetching data from DB.")
 	return products, nil
 }
 
-func (p *Postgres) GetFilteredProducts(filters map[string][]string) ([]modules.Product, error) {
+func (p *Postgres) GetFilteredProducts(filters map[string][]string, pc modules.PriceContext) ([]modules.Product, error) {
+
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
 	cacheKey := "products:filtered:"
-	for k, v := range filters {
-		cacheKey += fmt.Sprintf("%s=%s;", k, v)
+	for _, k := range keys {
+		cacheKey += fmt.Sprintf("%s=%s;", k, filters[k])
+	}
+	cacheKey, err := p.priceCacheKey(cacheKey, pc)
+	if err != nil {
+		return nil, err
 	}
 
 	if cached, err := cache.Rdb.Get(cache.Ctx, cacheKey).Result(); err == nil {
@@ -170,50 +292,168 @@ func (p *Postgres) GetFilteredProducts(filters map[string][]string) ([]modules.P
 		}
 	}
 
-	query := `SELECT product_id, name, price, stock, category_id, brand, images FROM products WHERE 1=1 `
+	where, args, err := p.productFilters(filters, pc.Currency, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products p WHERE ` + publicProduct + ` ` + where
+	query += "ORDER BY p.product_id DESC LIMIT 50"
+
+	rows, err := p.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []modules.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, product)
+	}
+
+	ttl, err := p.priceProducts(products, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(products) > 0 {
+		data, _ := json.Marshal(products)
+		cache.Rdb.Set(cache.Ctx, cacheKey, data, ttl)
+		fmt.Println("Cache missed data stored for filters:", cacheKey)
+	}
+
+	return products, nil
+}
+
+// productFilters builds the "AND ..." conditions for the /products/filtered
+// query parameters, numbering placeholders from argID. min_price and
+// max_price are in currency's minor units, or compared with stored prices
+// when currency is empty.
+func (p *Postgres) productFilters(filters map[string][]string, currency string, argID int) (string, []any, error) {
+	query := ""
 	args := []any{}
-	argID := 1
 
-	// Building Dynamic query
+	types, err := p.attributeTypes(filters)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	if name, ok := filters["name"]; ok {
-		query += fmt.Sprintf("AND name ILIKE $%d ", argID)
+		query += fmt.Sprintf("AND p.name ILIKE $%d ", argID)
 		args = append(args, "%"+name[0]+"%")
 		argID++
 	}
 
 	if brand, ok := filters["brand"]; ok {
-		query += fmt.Sprintf("AND brand = $%d ", argID)
+		query += fmt.Sprintf("AND p.brand = $%d ", argID)
 		args = append(args, brand[0])
 		argID++
 	}
 
 	if category, ok := filters["category_id"]; ok {
-		query += fmt.Sprintf("AND category_id = $%d ", argID)
+		query += fmt.Sprintf("AND p.category_id = $%d ", argID)
 		args = append(args, category[0])
 		argID++
 	}
 
-	if minPrice, ok := filters["min_price"]; ok {
-		query += fmt.Sprintf("AND price >= $%d ", argID)
-		args = append(args, minPrice[0])
-		argID++
-	}
+	_, hasMin := filters["min_price"]
+	_, hasMax := filters["max_price"]
+	if hasMin || hasMax {
+		price := "p.price"
+		if currency != "" {
+			r := &priceResolver{currency: currency, rates: map[string]float64{}}
+			if err := p.loadRates(r); err != nil {
+				return "", nil, err
+			}
+			var priceArgs []any
+			price, priceArgs = priceInCurrency(currency, r.rates, argID)
+			args = append(args, priceArgs...)
+			argID += len(priceArgs)
+		}
 
-	if maxPrice, ok := filters["max_price"]; ok {
-		query += fmt.Sprintf("AND price <= $%d ", argID)
-		args = append(args, maxPrice[0])
-		argID++
+		if minPrice, ok := filters["min_price"]; ok {
+			query += fmt.Sprintf("AND %s >= $%d ", price, argID)
+			args = append(args, minPrice[0])
+			argID++
+		}
+
+		if maxPrice, ok := filters["max_price"]; ok {
+			query += fmt.Sprintf("AND %s <= $%d ", price, argID)
+			args = append(args, maxPrice[0])
+			argID++
+		}
 	}
 
 	if stockGT, ok := filters["stock_gt"]; ok {
-		query += fmt.Sprintf("AND stock > $%d ", argID)
+		query += fmt.Sprintf("AND p.stock > $%d ", argID)
 		args = append(args, stockGT[0])
 		argID++
 	}
 
-	query += "ORDER BY product_id DESC LIMIT 50"
+	if status, ok := filters["status"]; ok {
+		query += fmt.Sprintf("AND p.status = $%d ", argID)
+		args = append(args, status[0])
+		argID++
+	}
 
-	rows, err := p.Db.Query(query, args...)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, "attr.") {
+			continue
+		}
+		name, op, value, err := parseAttributeFilter(k, filters[k][0])
+		if err != nil {
+			return "", nil, err
+		}
+		clause, clauseArgs, err := attributeFilter(name, op, value, types[name], argID)
+		if err != nil {
+			return "", nil, err
+		}
+		query += clause
+		args = append(args, clauseArgs...)
+		argID += len(clauseArgs)
+	}
+
+	return query, args, nil
+}
+
+// priceInCurrency returns an SQL expression for a product's regular price
+// in currency's minor units, as priceResolver.regular works it out: its
+// explicit price in currency, or its stored price converted with rates,
+// which map each source currency to units of currency. It is NULL for
+// products whose currency has no rate. Placeholders are numbered from argID.
+func priceInCurrency(currency string, rates map[string]float64, argID int) (string, []any) {
+	from := make([]string, 0, len(rates))
+	for c := range rates {
+		from = append(from, c)
+	}
+	sort.Strings(from)
+
+	args := []any{currency}
+	cases := fmt.Sprintf("WHEN $%d THEN p.price ", argID)
+	for _, c := range from {
+		factor := rates[c] * float64(modules.MinorUnitFactor(currency)) / float64(modules.MinorUnitFactor(c))
+		cases += fmt.Sprintf("WHEN $%d THEN ROUND(p.price * $%d::float8) ", argID+len(args), argID+len(args)+1)
+		args = append(args, c, factor)
+	}
+
+	return fmt.Sprintf("COALESCE((SELECT pp.amount FROM product_prices pp WHERE pp.product_id = p.product_id AND pp.currency = $%d), CASE p.currency %sEND)", argID, cases), args
+}
+
+func (p *Postgres) SearchProducts(qureyStr string, pc modules.PriceContext) ([]modules.Product, error) {
+	sqlQurey := `SELECT ` + productColumns + ` FROM products p WHERE ` + publicProduct + ` AND (p.name ILIKE $1 OR p.brand ILIKE $1) ORDER BY p.product_id DESC LIMIT 50;`
+
+	rows, err := p.Db.Query(sqlQurey, "%"+qureyStr+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -221,28 +461,353 @@ func (p *Postgres) GetFilteredProducts(filters map[string][]string) ([]modules.P
 
 	var products []modules.Product
 	for rows.Next() {
-		var product modules.Product
-		err := rows.Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Brand, pq.Array(&product.Images))
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, err
 		}
-
 		products = append(products, product)
 	}
 
-	if len(products) > 0 {
-		data, _ := json.Marshal(products)
-		cache.Rdb.Set(cache.Ctx, cacheKey, data, 7*24*time.Hour)
-		fmt.Println("Cache missed data stored for filters:", cacheKey)
+	if _, err := p.priceProducts(products, pc); err != nil {
+		return nil, err
 	}
 
 	return products, nil
+
+}
+
+func (p *Postgres) UpdateProductById(id int, version int, product modules.Product, actorId int) (modules.Product, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.Product{}, err
+	}
+	defer tx.Rollback()
+
+	updated, err := p.updateProduct(tx, id, version, product, actorId)
+	if err != nil {
+		// A held price leaves the rest of the update written.
+		if err = commitHeld(tx, err); errors.As(err, new(*storage.HeldForApproval)) {
+			InvalidateProductCache()
+		}
+		return modules.Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.Product{}, err
+	}
+
+	InvalidateProductCache()
+	return updated, nil
 }
 
-func (p *Postgres) SearchProducts(qureyStr string) ([]modules.Product, error) {
-	sqlQurey := `SELECT product_id, name, price, stock, category_id, brand, images FROM products WHERE name ILIKE $1 OR brand ILIKE $1 ORDER BY product_id DESC LIMIT 50;`
+func (p *Postgres) updateProduct(tx *sql.Tx, id int, version int, product modules.Product, actorId int) (modules.Product, error) {
+	if err := p.validateAttributes(product.CategoryID, product.Attributes); err != nil {
+		return modules.Product{}, err
+	}
 
-	rows, err := p.Db.Query(sqlQurey, "%"+qureyStr+"%")
+	before, err := lockProduct(tx, id, version)
+	if err != nil {
+		return modules.Product{}, err
+	}
+
+	// A price that needs approval is held on its own; the rest of the
+	// product is written now, keeping the current price.
+	price, holdPrice := product.Price, priceApprovalReason(p.approval, before.Price, product.Price) != ""
+	if holdPrice {
+		product.Price = before.Price
+	}
+
+	values := append(p.productWriteValues(product), id)
+	updated, err := scanProduct(tx.QueryRow("UPDATE products p SET ("+productWriteColumns+") = ("+placeholders(1, len(values)-1)+"), version = version + 1 WHERE p.product_id = $"+fmt.Sprint(len(values))+" RETURNING "+productColumns, values...))
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error updating product: %v", err)
+	}
+
+	if err := recordRevision(tx, "update", &before, &updated, actorId); err != nil {
+		return modules.Product{}, err
+	}
+
+	if holdPrice {
+		return updated, p.holdProductPrice(tx, updated, price, actorId)
+	}
+	return updated, nil
+}
+
+// holdProductPrice holds moving product to price for approval. The change
+// request carries only the price, based on the version the rest of the
+// write left product at, so approving it never replays stock or other
+// fields captured when it was held.
+func (p *Postgres) holdProductPrice(tx *sql.Tx, product modules.Product, price modules.Money, actorId int) error {
+	proposed := product
+	proposed.Price = price
+	cr := modules.ChangeRequest{ProductId: product.ProductId, BaseVersion: product.Version, Action: "update", Proposed: proposed, Fields: []string{"price"}}
+	return p.holdPriceChange(tx, product.Price, price, cr, actorId)
+}
+
+// DeleteProductById moves a product to the trash, unless the deletion is
+// held for approval.
+func (p *Postgres) DeleteProductById(id int, version int, actorId int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	product, err := lockProduct(tx, id, version)
+	if err != nil {
+		return err
+	}
+
+	if err := p.holdDeletion(tx, product, actorId); err != nil {
+		return commitHeld(tx, err)
+	}
+
+	if err := softDeleteProduct(tx, id, version, actorId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidateProductCache()
+
+	return nil
+
+}
+
+func softDeleteProduct(tx *sql.Tx, id int, version int, actorId int) error {
+	before, err := lockProduct(tx, id, version)
+	if err != nil {
+		return err
+	}
+
+	after, err := scanProduct(tx.QueryRow("UPDATE products p SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE p.product_id = $1 RETURNING "+productColumns, id))
+	if err != nil {
+		return fmt.Errorf("error deleting product %w", err)
+	}
+
+	return recordRevision(tx, "delete", &before, &after, actorId)
+}
+
+// GetAdminProductById reads a product straight from the database, bypassing
+// the cache, for admin edits that must start from the current row.
+func (p *Postgres) GetAdminProductById(id int) (modules.Product, error) {
+	product, err := scanProduct(p.Db.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.product_id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return modules.Product{}, fmt.Errorf("%w: product with id %d", storage.ErrNotFound, id)
+		}
+		return modules.Product{}, fmt.Errorf("error fetching product: %v", err)
+	}
+
+	return product, nil
+}
+
+// patchableColumns whitelists the columns PatchProductById may write.
+var patchableColumns = map[string]bool{
+	"name": true, "price": true, "stock": true, "category_id": true,
+	"quantity": true, "brand": true, "images": true, "attributes": true,
+	"status": true, "publish_at": true, "sku": true, "external_id": true,
+}
+
+// PatchProductById updates only the given columns of a product. Values are
+// keyed by column name.
+func (p *Postgres) PatchProductById(id int, version int, changes map[string]any, actorId int) (modules.Product, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.Product{}, err
+	}
+	defer tx.Rollback()
+
+	product, err := p.patchProduct(tx, id, version, changes, actorId)
+	if err != nil {
+		// A held price leaves the other columns written.
+		if err = commitHeld(tx, err); errors.As(err, new(*storage.HeldForApproval)) {
+			InvalidateProductCacheById(id)
+		}
+		return modules.Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.Product{}, err
+	}
+
+	InvalidateProductCacheById(id)
+	return product, nil
+}
+
+// patchProduct locks the product at version and applies changes. A price
+// moved too far is held for approval on its own, and the other columns are
+// written.
+func (p *Postgres) patchProduct(tx *sql.Tx, id int, version int, changes map[string]any, actorId int) (modules.Product, error) {
+	before, err := lockProduct(tx, id, version)
+	if err != nil {
+		return modules.Product{}, err
+	}
+
+	price, holdPrice := changes["price"].(modules.Money)
+	if holdPrice && priceApprovalReason(p.approval, before.Price, price) != "" {
+		rest := make(map[string]any, len(changes))
+		for column, value := range changes {
+			if column != "price" {
+				rest[column] = value
+			}
+		}
+
+		updated, err := p.applyPatch(tx, before, rest, actorId)
+		if err != nil {
+			return modules.Product{}, err
+		}
+		return updated, p.holdProductPrice(tx, updated, price, actorId)
+	}
+
+	return p.applyPatch(tx, before, changes, actorId)
+}
+
+// applyPatch writes changes over before, which the caller has locked.
+func (p *Postgres) applyPatch(tx *sql.Tx, before modules.Product, changes map[string]any, actorId int) (modules.Product, error) {
+	if len(changes) == 0 {
+		return before, nil
+	}
+
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		if !patchableColumns[column] {
+			return modules.Product{}, fmt.Errorf("column %q cannot be patched", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	_, attributesChanged := changes["attributes"]
+	_, categoryChanged := changes["category_id"]
+	if attributesChanged || categoryChanged {
+		categoryId, attributes := before.CategoryID, before.Attributes
+		if v, ok := changes["category_id"].(string); ok {
+			categoryId = v
+		}
+		if v, ok := changes["attributes"]; ok {
+			attributes, _ = v.(map[string]any)
+		}
+		if err := p.validateAttributes(categoryId, attributes); err != nil {
+			return modules.Product{}, err
+		}
+	}
+
+	sets := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns)+2)
+	for _, column := range columns {
+		value := changes[column]
+		switch column {
+		case "price":
+			price, _ := value.(modules.Money)
+			sets = append(sets, fmt.Sprintf("currency = $%d", len(args)+1))
+			args = append(args, p.currencyOf(price))
+			value = price.Amount
+		case "images":
+			images, _ := value.([]modules.ProductImage)
+			value = jsonb{nonNilImages(images)}
+		case "attributes":
+			attributes, _ := value.(map[string]any)
+			value = jsonb{nonNilAttributes(attributes)}
+		case "status":
+			status, _ := value.(string)
+			value = productStatus(status)
+		case "sku", "external_id":
+			str, _ := value.(string)
+			value = nullableString(str)
+		}
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)+1))
+		args = append(args, value)
+	}
+	args = append(args, before.ProductId)
+
+	query := fmt.Sprintf("UPDATE products p SET %s, version = version + 1 WHERE p.product_id = $%d RETURNING %s", strings.Join(sets, ", "), len(args), productColumns)
+
+	product, err := scanProduct(tx.QueryRow(query, args...))
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error patching product: %v", err)
+	}
+
+	if err := recordRevision(tx, "update", &before, &product, actorId); err != nil {
+		return modules.Product{}, err
+	}
+
+	return product, nil
+}
+
+// productChanges picks the given columns' values out of product in the form
+// patchProduct expects.
+func productChanges(product modules.Product, columns []string) map[string]any {
+	changes := make(map[string]any, len(columns))
+	for _, column := range columns {
+		switch column {
+		case "name":
+			changes[column] = product.Name
+		case "price":
+			changes[column] = product.Price
+		case "stock":
+			changes[column] = product.Stock
+		case "category_id":
+			changes[column] = product.CategoryID
+		case "quantity":
+			changes[column] = product.Quantity
+		case "brand":
+			changes[column] = product.Brand
+		case "images":
+			changes[column] = product.Images
+		case "attributes":
+			changes[column] = product.Attributes
+		case "status":
+			changes[column] = product.Status
+		case "publish_at":
+			changes[column] = product.PublishAt
+		case "sku":
+			changes[column] = product.SKU
+		case "external_id":
+			changes[column] = product.ExternalId
+		}
+	}
+	return changes
+}
+
+// withChanges returns product with changes, keyed by column as patchProduct
+// takes them, applied. It is the inverse of productChanges.
+func withChanges(product modules.Product, changes map[string]any) modules.Product {
+	for column, value := range changes {
+		switch column {
+		case "name":
+			product.Name, _ = value.(string)
+		case "price":
+			product.Price, _ = value.(modules.Money)
+		case "stock":
+			product.Stock, _ = value.(int)
+		case "category_id":
+			product.CategoryID, _ = value.(string)
+		case "quantity":
+			product.Quantity, _ = value.(int)
+		case "brand":
+			product.Brand, _ = value.(string)
+		case "images":
+			product.Images, _ = value.([]modules.ProductImage)
+		case "attributes":
+			product.Attributes, _ = value.(map[string]any)
+		case "status":
+			product.Status, _ = value.(string)
+		case "publish_at":
+			product.PublishAt, _ = value.(*time.Time)
+		case "sku":
+			product.SKU, _ = value.(string)
+		case "external_id":
+			product.ExternalId, _ = value.(string)
+		}
+	}
+	return product
+}
+
+func (p *Postgres) GetDeletedProducts() ([]modules.Product, error) {
+	rows, err := p.Db.Query("SELECT " + productColumns + " FROM products p WHERE p.deleted_at IS NOT NULL ORDER BY p.deleted_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -250,52 +815,240 @@ func (p *Postgres) SearchProducts(qureyStr string) ([]modules.Product, error) {
 
 	var products []modules.Product
 	for rows.Next() {
-		var product modules.Product
-		if err := rows.Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Brand, pq.Array(&product.Images)); err != nil {
+		product, err := scanProduct(rows)
+		if err != nil {
 			return nil, err
 		}
 		products = append(products, product)
 	}
 
-	return products, nil
-
+	return products, rows.Err()
 }
 
-func (p *Postgres) UpdateProductById(id int, name string, price int, stock int, categoryId string, quantity int, Brand string, Images []string) (modules.Product, error) {
-	stmt, err := p.Db.Prepare("UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4, quantity=$5, brand = $6, images = $7 WHERE id = $8 RETURNING *")
+// RestoreDeletedProduct takes a soft-deleted product out of the trash.
+func (p *Postgres) RestoreDeletedProduct(id int, actorId int) (modules.Product, error) {
+	tx, err := p.Db.Begin()
 	if err != nil {
 		return modules.Product{}, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	var product modules.Product
-	err = stmt.QueryRow(name, price, stock, categoryId, quantity, Brand, pq.Array(Images), id).Scan(&product.ProductId, &product.Name, &product.Price, &product.Stock, &product.CategoryID, &product.Quantity, &product.Brand, pq.Array(&product.Images))
+	before, err := scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.product_id = $1 AND p.deleted_at IS NOT NULL FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return modules.Product{}, fmt.Errorf("%w: product with id %d is not in the trash", storage.ErrNotFound, id)
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return modules.Product{}, fmt.Errorf("product with id %d not found", id)
-		}
-		return modules.Product{}, fmt.Errorf("error updating product: %v", err)
+		return modules.Product{}, fmt.Errorf("error fetching product: %v", err)
+	}
+
+	product, err := scanProduct(tx.QueryRow("UPDATE products p SET deleted_at = NULL, version = version + 1 WHERE p.product_id = $1 RETURNING "+productColumns, id))
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error restoring product: %v", err)
+	}
+
+	if err := recordRevision(tx, "restore", &before, &product, actorId); err != nil {
+		return modules.Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.Product{}, err
 	}
 
 	InvalidateProductCache()
 	return product, nil
 }
 
-func (p *Postgres) DeleteProductById(id int) error {
-	stmt, err := p.Db.Prepare("DELETE FROM products WHERE id = $1")
+// PurgeDeletedProducts hard-deletes products and variants that have been in
+// the trash for longer than retention. Cart and wishlist rows go with them
+// via ON DELETE CASCADE.
+func (p *Postgres) PurgeDeletedProducts(retention time.Duration) (int, error) {
+	res, err := p.Db.Exec("DELETE FROM products WHERE deleted_at < $1", time.Now().Add(-retention))
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("error purging deleted products: %w", err)
 	}
+	n, _ := res.RowsAffected()
 
-	defer stmt.Close()
+	res, err = p.Db.Exec("DELETE FROM product_variants WHERE deleted_at < $1", time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("error purging deleted variants: %w", err)
+	}
+	variants, _ := res.RowsAffected()
 
-	_, err = stmt.Exec(id)
+	return int(n + variants), nil
+}
+
+// productStatus defaults an unset status to published, matching products
+// created before the lifecycle existed.
+func productStatus(status string) string {
+	if status == "" {
+		return modules.StatusPublished
+	}
+	return status
+}
+
+// PublishScheduledProducts publishes scheduled products whose publish_at has
+// passed and returns their ids.
+func (p *Postgres) PublishScheduledProducts() ([]int, error) {
+	tx, err := p.Db.Begin()
 	if err != nil {
-		return fmt.Errorf("error deleting product %w", err)
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	InvalidateProductCache()
+	rows, err := tx.Query("SELECT "+productColumns+" FROM products p WHERE p.status = $1 AND p.publish_at <= CURRENT_TIMESTAMP AND p.deleted_at IS NULL FOR UPDATE SKIP LOCKED", modules.StatusScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching scheduled products: %w", err)
+	}
+
+	var due []modules.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, product)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var published []int
+	for i := range due {
+		product, err := scanProduct(tx.QueryRow("UPDATE products p SET status = $1, version = version + 1 WHERE p.product_id = $2 RETURNING "+productColumns, modules.StatusPublished, due[i].ProductId))
+		if err != nil {
+			return nil, fmt.Errorf("error publishing product %d: %w", due[i].ProductId, err)
+		}
+
+		if err := recordRevision(tx, "update", &due[i], &product, 0); err != nil {
+			return nil, err
+		}
+		published = append(published, product.ProductId)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range published {
+		InvalidateProductCacheById(id)
+	}
+
+	return published, nil
+}
+
+// lockProduct reads a product FOR UPDATE inside tx and checks that the caller
+// saw its current version.
+func lockProduct(tx *sql.Tx, id int, version int) (modules.Product, error) {
+	product, err := scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.product_id = $1 AND p.deleted_at IS NULL FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return modules.Product{}, fmt.Errorf("%w: product with id %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error fetching product: %v", err)
+	}
+
+	if product.Version != version {
+		return modules.Product{}, fmt.Errorf("%w: product %d is at version %d", storage.ErrVersionMismatch, id, product.Version)
+	}
+
+	return product, nil
+}
+
+// StreamProducts calls fn for every live product matching the
+// /products/filtered parameters, whatever its status, in product id order.
+// Rows are read from Postgres as fn consumes them rather than collected first.
+func (p *Postgres) StreamProducts(filters map[string][]string, fn func(modules.Product) error) error {
+	where, args, err := p.productFilters(filters, "", 1)
+	if err != nil {
+		return err
+	}
+
+	rows, err := p.Db.Query(`SELECT `+productColumns+` FROM products p WHERE p.deleted_at IS NULL `+where+`ORDER BY p.product_id`, args...)
+	if err != nil {
+		return fmt.Errorf("error fetching products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning product: %w", err)
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
 
 	return nil
+}
+
+// GetAdminProducts collects what StreamProducts returns, for bulk admin
+// operations.
+func (p *Postgres) GetAdminProducts(filters map[string][]string) ([]modules.Product, error) {
+	var products []modules.Product
+	err := p.StreamProducts(filters, func(product modules.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	return products, err
+}
+
+// SetProductPrice sets a product's price, in minor units of its current
+// currency, whatever its version, recording a revision. It reports false when
+// the price already had that value, so a retried bulk update does not write
+// twice. A change that needs approval is held and returned as a
+// *storage.HeldForApproval.
+func (p *Postgres) SetProductPrice(id int, amount int64, actorId int) (bool, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var current modules.Money
+	var version int
+	err = tx.QueryRow(`SELECT price, currency, version FROM products WHERE product_id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&current.Amount, &current.Currency, &version)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("%w: product with id %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error fetching product: %v", err)
+	}
+
+	if current.Amount == amount {
+		return false, nil
+	}
 
+	price := modules.Money{Amount: amount, Currency: current.Currency}
+	if _, err := p.patchProduct(tx, id, version, map[string]any{"price": price}, actorId); err != nil {
+		return false, commitHeld(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	InvalidateProductCacheById(id)
+	return true, nil
+}
+
+// ReindexProducts rebuilds the products table's indexes without blocking
+// writes, refreshes planner statistics and drops cached listings.
+func (p *Postgres) ReindexProducts() error {
+	if _, err := p.Db.Exec(`REINDEX TABLE CONCURRENTLY products`); err != nil {
+		return fmt.Errorf("error reindexing products: %w", err)
+	}
+
+	if _, err := p.Db.Exec(`ANALYZE products`); err != nil {
+		return fmt.Errorf("error analyzing products: %w", err)
+	}
+
+	InvalidateProductCache()
+	return nil
 }
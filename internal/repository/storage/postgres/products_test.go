@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPriceInCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		rates    map[string]float64
+		want     map[string]float64
+	}{
+		{
+			name:     "same currency only",
+			currency: "EUR",
+			rates:    map[string]float64{},
+			want:     map[string]float64{},
+		},
+		{
+			name:     "two-decimal to two-decimal",
+			currency: "EUR",
+			rates:    map[string]float64{"USD": 0.9},
+			want:     map[string]float64{"USD": 0.9},
+		},
+		{
+			name:     "zero-decimal source",
+			currency: "EUR",
+			rates:    map[string]float64{"JPY": 0.006},
+			want:     map[string]float64{"JPY": 0.6},
+		},
+		{
+			name:     "three-decimal target",
+			currency: "KWD",
+			rates:    map[string]float64{"USD": 0.3},
+			want:     map[string]float64{"USD": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, args := priceInCurrency(tt.currency, tt.rates, 3)
+
+			if !strings.Contains(expr, "pp.currency = $3") || !strings.Contains(expr, "WHEN $3 THEN p.price ") {
+				t.Errorf("expression does not use the explicit or same-currency price: %s", expr)
+			}
+			if args[0] != tt.currency {
+				t.Errorf("args[0] = %v, want %s", args[0], tt.currency)
+			}
+
+			got := map[string]float64{}
+			for i := 1; i+1 < len(args); i += 2 {
+				got[args[i].(string)] = args[i+1].(float64)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("factors = %v, want %v", got, tt.want)
+			}
+			for c, want := range tt.want {
+				if math.Abs(got[c]-want) > 1e-9 {
+					t.Errorf("factor for %s = %v, want %v", c, got[c], want)
+				}
+			}
+		})
+	}
+}
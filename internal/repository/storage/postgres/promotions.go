@@ -0,0 +1,372 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/promotions"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+const promotionColumns = `pr.promotion_id, pr.name, COALESCE(pr.code, ''), pr.type, pr.percent, pr.amount, pr.min_spend, pr.currency,
+	COALESCE(pr.product_id, 0), pr.buy_quantity, pr.get_quantity, pr.per_user_limit, pr.starts_at, pr.ends_at, pr.disabled`
+
+func scanPromotion(row rowScanner) (modules.Promotion, error) {
+	var promo modules.Promotion
+	var amount, minSpend sql.NullInt64
+	var currency sql.NullString
+	err := row.Scan(&promo.PromotionId, &promo.Name, &promo.Code, &promo.Type, &promo.Percent, &amount, &minSpend, &currency,
+		&promo.ProductId, &promo.BuyQuantity, &promo.GetQuantity, &promo.PerUserLimit, &promo.StartsAt, &promo.EndsAt, &promo.Disabled)
+	if amount.Valid {
+		promo.Amount = &modules.Money{Amount: amount.Int64, Currency: currency.String}
+	}
+	if minSpend.Valid {
+		promo.MinSpend = &modules.Money{Amount: minSpend.Int64, Currency: currency.String}
+	}
+	return promo, err
+}
+
+// promotionValues returns the values written for promo's columns, in the
+// order of CreatePromotion's insert. Amount and MinSpend share a currency.
+func (p *Postgres) promotionValues(promo modules.Promotion) []any {
+	var amount, minSpend any
+	currency := ""
+	if promo.Amount != nil {
+		amount, currency = promo.Amount.Amount, p.currencyOf(*promo.Amount)
+	}
+	if promo.MinSpend != nil {
+		minSpend = promo.MinSpend.Amount
+		if currency == "" {
+			currency = p.currencyOf(*promo.MinSpend)
+		}
+	}
+
+	return []any{promo.Name, nullableString(promo.Code), promo.Type, promo.Percent, amount, minSpend, nullableString(currency),
+		nullableId(promo.ProductId), promo.BuyQuantity, promo.GetQuantity, promo.PerUserLimit, promo.StartsAt, promo.EndsAt, promo.Disabled}
+}
+
+// promotionError reports a duplicate code as ErrConflict and an unknown
+// product as ErrNotFound.
+func promotionError(err error, promo modules.Promotion) error {
+	if hasPqCode(err, "23505") {
+		return fmt.Errorf("%w: coupon code %q is already in use", storage.ErrConflict, promo.Code)
+	}
+	if hasPqCode(err, "23503") {
+		return fmt.Errorf("%w: product %d", storage.ErrNotFound, promo.ProductId)
+	}
+	return fmt.Errorf("failed to save promotion: %w", err)
+}
+
+func (p *Postgres) CreatePromotion(promo modules.Promotion) (modules.Promotion, error) {
+	created, err := scanPromotion(p.Db.QueryRow(`
+		INSERT INTO promotions AS pr (name, code, type, percent, amount, min_spend, currency, product_id, buy_quantity, get_quantity, per_user_limit, starts_at, ends_at, disabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING `+promotionColumns, p.promotionValues(promo)...))
+	if err != nil {
+		return modules.Promotion{}, promotionError(err, promo)
+	}
+	return created, nil
+}
+
+func (p *Postgres) UpdatePromotion(id int, promo modules.Promotion) (modules.Promotion, error) {
+	values := append(p.promotionValues(promo), id)
+	updated, err := scanPromotion(p.Db.QueryRow(`
+		UPDATE promotions AS pr SET name = $1, code = $2, type = $3, percent = $4, amount = $5, min_spend = $6, currency = $7,
+			product_id = $8, buy_quantity = $9, get_quantity = $10, per_user_limit = $11, starts_at = $12, ends_at = $13, disabled = $14
+		WHERE pr.promotion_id = $15
+		RETURNING `+promotionColumns, values...))
+	if err == sql.ErrNoRows {
+		return modules.Promotion{}, fmt.Errorf("%w: promotion %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return modules.Promotion{}, promotionError(err, promo)
+	}
+	return updated, nil
+}
+
+func (p *Postgres) GetPromotions() ([]modules.Promotion, error) {
+	rows, err := p.Db.Query(`SELECT ` + promotionColumns + ` FROM promotions pr ORDER BY pr.promotion_id`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promotions: %w", err)
+	}
+	defer rows.Close()
+
+	promos := []modules.Promotion{}
+	for rows.Next() {
+		promo, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+
+	return promos, rows.Err()
+}
+
+func (p *Postgres) DeletePromotion(id int) error {
+	res, err := p.Db.Exec(`DELETE FROM promotions WHERE promotion_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: promotion %d", storage.ErrNotFound, id)
+	}
+	return nil
+}
+
+// activePromotions returns the promotions a cart qualifies for before
+// looking at its contents: automatic ones and the one matching coupon,
+// within their window and under the owner's usage limit. Automatic
+// promotions come first so a coupon discounts what they leave.
+func activePromotions(tx *sql.Tx, owner modules.CartOwner, coupon string) ([]modules.Promotion, error) {
+	rows, err := tx.Query(`
+		SELECT `+promotionColumns+` FROM promotions pr
+		WHERE NOT pr.disabled
+			AND (pr.starts_at IS NULL OR pr.starts_at <= NOW())
+			AND (pr.ends_at IS NULL OR pr.ends_at > NOW())
+			AND (pr.code IS NULL OR pr.code = $1)
+		ORDER BY pr.code IS NOT NULL, pr.promotion_id
+	`, coupon)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promos []modules.Promotion
+	for rows.Next() {
+		promo, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	used, err := redemptions(tx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return promotions.Redeemable(promos, used), nil
+}
+
+// redemptions counts, by promotion id, the orders owner has placed with
+// each promotion applied.
+func redemptions(tx *sql.Tx, owner modules.CartOwner) (map[int]int, error) {
+	column, value := cartOwnerColumn(owner)
+	rows, err := tx.Query(`SELECT promotion_id, COUNT(*) FROM promotion_redemptions WHERE `+column+` = $1 GROUP BY promotion_id`, value)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	used := map[int]int{}
+	for rows.Next() {
+		var promotionId, count int
+		if err := rows.Scan(&promotionId, &count); err != nil {
+			return nil, err
+		}
+		used[promotionId] = count
+	}
+
+	return used, rows.Err()
+}
+
+// redeemPromotions records the promotions applied to cartId against owner,
+// once the cart is ordered.
+func redeemPromotions(tx *sql.Tx, cartId int, owner modules.CartOwner, applied []modules.AppliedPromotion) error {
+	for _, promo := range applied {
+		_, err := tx.Exec(`
+			INSERT INTO promotion_redemptions (promotion_id, cart_id, user_id, guest_token, discount, currency)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, promo.PromotionId, cartId, nullableId(owner.UserId), nullableString(owner.GuestId), promo.Discount.Amount, promo.Discount.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to record redemption: %w", err)
+		}
+	}
+	return nil
+}
+
+// recomputeCart runs the promotions engine over a cart and stores each
+// line's discount and subtotal. It returns the cart totals and, by promotion
+// id, why any active promotion did not apply.
+func (p *Postgres) recomputeCart(tx *sql.Tx, cartId int) (modules.CartTotals, map[int]string, error) {
+	var owner modules.CartOwner
+	var coupon sql.NullString
+	err := tx.QueryRow(`SELECT COALESCE(user_id, 0), COALESCE(guest_token, ''), coupon_code FROM cartTable WHERE cart_id = $1 FOR UPDATE`, cartId).Scan(&owner.UserId, &owner.GuestId, &coupon)
+	if err != nil {
+		return modules.CartTotals{}, nil, fmt.Errorf("failed to fetch cart: %w", err)
+	}
+
+	rows, err := tx.Query(`SELECT cart_item_id, product_id, quantity, price_at_time, currency FROM cartItems WHERE cart_id = $1 ORDER BY cart_item_id`, cartId)
+	if err != nil {
+		return modules.CartTotals{}, nil, fmt.Errorf("error fetching cart items: %w", err)
+	}
+
+	var lines []promotions.Line
+	for rows.Next() {
+		var line promotions.Line
+		if err := rows.Scan(&line.Id, &line.ProductId, &line.Quantity, &line.UnitPrice.Amount, &line.UnitPrice.Currency); err != nil {
+			rows.Close()
+			return modules.CartTotals{}, nil, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return modules.CartTotals{}, nil, err
+	}
+
+	promos, err := activePromotions(tx, owner, coupon.String)
+	if err != nil {
+		return modules.CartTotals{}, nil, err
+	}
+
+	result := promotions.Apply(lines, promos)
+
+	currency := p.currency
+	if len(lines) > 0 {
+		currency = lines[0].UnitPrice.Currency
+	}
+	totals := modules.CartTotals{
+		Subtotal:   modules.Money{Currency: currency},
+		Discount:   modules.Money{Currency: currency},
+		Total:      modules.Money{Currency: currency},
+		Coupon:     coupon.String,
+		Promotions: []modules.AppliedPromotion{},
+	}
+
+	for _, line := range lines {
+		gross := line.UnitPrice.Times(int64(line.Quantity)).Amount
+		discount := result.Discounts[line.Id]
+
+		_, err := tx.Exec(`UPDATE cartItems SET discount = $1, subtotal = $2 WHERE cart_item_id = $3`, discount, gross-discount, line.Id)
+		if err != nil {
+			return modules.CartTotals{}, nil, fmt.Errorf("failed to update cart item: %w", err)
+		}
+
+		totals.Subtotal.Amount += gross
+		totals.Discount.Amount += discount
+	}
+	totals.Total.Amount = totals.Subtotal.Amount - totals.Discount.Amount
+
+	if _, err := tx.Exec(`DELETE FROM cart_promotions WHERE cart_id = $1`, cartId); err != nil {
+		return modules.CartTotals{}, nil, fmt.Errorf("failed to update cart promotions: %w", err)
+	}
+	for _, applied := range result.Applied {
+		_, err := tx.Exec(`INSERT INTO cart_promotions (cart_id, promotion_id, discount) VALUES ($1, $2, $3)`, cartId, applied.PromotionId, applied.Discount.Amount)
+		if err != nil {
+			return modules.CartTotals{}, nil, fmt.Errorf("failed to update cart promotions: %w", err)
+		}
+		totals.Promotions = append(totals.Promotions, applied)
+	}
+
+	return totals, result.Skipped, nil
+}
+
+// cartOwnerColumn returns the cartTable column and value that pick out
+// owner's carts.
+func cartOwnerColumn(owner modules.CartOwner) (string, any) {
+	if owner.GuestId != "" {
+		return "guest_token", owner.GuestId
+	}
+	return "user_id", owner.UserId
+}
+
+// activeCartId returns the owner's active cart, locked for the transaction,
+// and marks it as used now so PurgeGuestCarts counts from its last use.
+func activeCartId(tx *sql.Tx, owner modules.CartOwner) (int, error) {
+	var cartId int
+	column, value := cartOwnerColumn(owner)
+	err := tx.QueryRow(`UPDATE cartTable SET updated_at = NOW() WHERE `+column+` = $1 AND status = 'active' RETURNING cart_id`, value).Scan(&cartId)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: no active cart", storage.ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch cart: %w", err)
+	}
+	return cartId, nil
+}
+
+// ApplyCoupon puts a coupon code on the owner's active cart. It fails, and
+// leaves the cart as it was, when the code is unknown, outside its window,
+// used up, or takes nothing off this cart.
+func (p *Postgres) ApplyCoupon(owner modules.CartOwner, code string) (modules.CartTotals, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+	defer tx.Rollback()
+
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+
+	column, value := cartOwnerColumn(owner)
+	var promotionId, limit, used int
+	var pending, expired bool
+	err = tx.QueryRow(`
+		SELECT pr.promotion_id, COALESCE(pr.starts_at > NOW(), FALSE), COALESCE(pr.ends_at <= NOW(), FALSE), pr.per_user_limit,
+			(SELECT COUNT(*) FROM promotion_redemptions r WHERE r.promotion_id = pr.promotion_id AND r.`+column+` = $2)
+		FROM promotions pr
+		WHERE pr.code = $1 AND NOT pr.disabled
+	`, code, value).Scan(&promotionId, &pending, &expired, &limit, &used)
+	if err == sql.ErrNoRows {
+		return modules.CartTotals{}, fmt.Errorf("%w: coupon %q", storage.ErrNotFound, code)
+	}
+	if err != nil {
+		return modules.CartTotals{}, fmt.Errorf("failed to fetch coupon: %w", err)
+	}
+
+	switch {
+	case pending:
+		return modules.CartTotals{}, fmt.Errorf("%w: coupon %q is not active yet", storage.ErrConflict, code)
+	case expired:
+		return modules.CartTotals{}, fmt.Errorf("%w: coupon %q has expired", storage.ErrConflict, code)
+	case limit > 0 && used >= limit:
+		return modules.CartTotals{}, fmt.Errorf("%w: coupon %q has already been used", storage.ErrConflict, code)
+	}
+
+	if _, err := tx.Exec(`UPDATE cartTable SET coupon_code = $1 WHERE cart_id = $2`, code, cartId); err != nil {
+		return modules.CartTotals{}, fmt.Errorf("failed to apply coupon: %w", err)
+	}
+
+	totals, skipped, err := p.recomputeCart(tx, cartId)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+	if reason, ok := skipped[promotionId]; ok {
+		return modules.CartTotals{}, fmt.Errorf("%w: coupon %q does not apply: %s", storage.ErrConflict, code, reason)
+	}
+
+	return totals, tx.Commit()
+}
+
+// RemoveCoupon takes the coupon off the owner's active cart.
+func (p *Postgres) RemoveCoupon(owner modules.CartOwner) (modules.CartTotals, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+	defer tx.Rollback()
+
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+
+	if _, err := tx.Exec(`UPDATE cartTable SET coupon_code = NULL WHERE cart_id = $1`, cartId); err != nil {
+		return modules.CartTotals{}, fmt.Errorf("failed to remove coupon: %w", err)
+	}
+
+	totals, _, err := p.recomputeCart(tx, cartId)
+	if err != nil {
+		return modules.CartTotals{}, err
+	}
+
+	return totals, tx.Commit()
+}
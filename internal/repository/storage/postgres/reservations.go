@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// lockAvailableStock locks the stock row of a product, or of its variant,
+// for the rest of tx and returns the stock not held by other carts'
+// unexpired reservations. Holding the lock serializes reservations of the
+// same item, so two carts cannot both claim the last unit.
+func lockAvailableStock(tx *sql.Tx, cartId int, productId int, variantId int) (int, error) {
+	var stock int
+	var err error
+	if variantId == 0 {
+		err = tx.QueryRow(`SELECT p.stock FROM products p WHERE p.product_id = $1 AND `+publicProduct+` FOR UPDATE`, productId).Scan(&stock)
+	} else {
+		err = tx.QueryRow(`
+			SELECT v.stock FROM product_variants v
+			JOIN products p ON p.product_id = v.product_id
+			WHERE v.variant_id = $1 AND v.product_id = $2 AND v.deleted_at IS NULL AND `+publicProduct+`
+			FOR UPDATE OF v
+		`, variantId, productId).Scan(&stock)
+	}
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: product %d is not available", storage.ErrNotFound, productId)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock stock: %w", err)
+	}
+
+	var reserved int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations
+		WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 AND cart_id <> $3 AND expires_at > NOW()
+	`, productId, nullableId(variantId), cartId).Scan(&reserved)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check reservations: %w", err)
+	}
+
+	return stock - reserved, nil
+}
+
+// holdStock sets cartId's reservation of a product or variant to quantity,
+// expiring cart.reservation_ttl from now, and records the change in the
+// ledger.
+func (p *Postgres) holdStock(tx *sql.Tx, cartId int, productId int, variantId int, quantity int) error {
+	_, err := tx.Exec(`
+		WITH previous AS (
+			SELECT COALESCE(SUM(quantity), 0) AS quantity FROM stock_reservations
+			WHERE cart_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+		), held AS (
+			INSERT INTO stock_reservations (cart_id, product_id, variant_id, quantity, expires_at)
+			VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+			ON CONFLICT (cart_id, product_id, (COALESCE(variant_id, 0)))
+			DO UPDATE SET quantity = EXCLUDED.quantity, expires_at = EXCLUDED.expires_at
+		)
+		INSERT INTO inventory_movements (product_id, variant_id, type, quantity, reason, cart_id)
+		SELECT $2, $3, 'reservation', $4 - quantity, 'cart reservation', $1 FROM previous WHERE quantity <> $4
+	`, cartId, productId, nullableId(variantId), quantity, p.cart.ReservationTTL.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to reserve stock: %w", err)
+	}
+	return nil
+}
+
+// reserveStock reserves quantity of a product or variant for cartId,
+// replacing the cart's earlier reservation of it. It fails with ErrConflict
+// when other carts leave too little stock.
+func (p *Postgres) reserveStock(tx *sql.Tx, cartId int, productId int, variantId int, quantity int) error {
+	available, err := lockAvailableStock(tx, cartId, productId, variantId)
+	if err != nil {
+		return err
+	}
+	if quantity > available {
+		return fmt.Errorf("%w: cannot reserve %d of product %d, only %d available", storage.ErrConflict, quantity, productId, max(available, 0))
+	}
+	return p.holdStock(tx, cartId, productId, variantId, quantity)
+}
+
+// ReserveCart renews the reservations of every line in the owner's active
+// cart, as when checkout starts, and returns them. Nothing is reserved
+// unless every line can be.
+func (p *Postgres) ReserveCart(owner modules.CartOwner) ([]modules.StockReservation, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := cartLineQuantities(tx, cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, line := range lines {
+		err := p.reserveStock(tx, cartId, line.productId, line.variantId, line.quantity)
+		if errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrNotFound) {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", storage.ErrConflict, strings.Join(problems, "; "))
+	}
+
+	reservations, err := cartReservations(tx, cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, tx.Commit()
+}
+
+// lineQuantity is a cart line's id, product, variant (0 for none) and
+// quantity.
+type lineQuantity struct {
+	id, productId, variantId, quantity int
+}
+
+// cartLineQuantities returns a cart's lines in product order. Locking stock
+// in that order keeps concurrent reservations from deadlocking.
+func cartLineQuantities(tx *sql.Tx, cartId int) ([]lineQuantity, error) {
+	rows, err := tx.Query(`
+		SELECT cart_item_id, product_id, COALESCE(variant_id, 0), quantity FROM cartItems
+		WHERE cart_id = $1 ORDER BY product_id, variant_id NULLS FIRST
+	`, cartId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching cart items: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []lineQuantity
+	for rows.Next() {
+		var line lineQuantity
+		if err := rows.Scan(&line.id, &line.productId, &line.variantId, &line.quantity); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+func cartReservations(tx *sql.Tx, cartId int) ([]modules.StockReservation, error) {
+	rows, err := tx.Query(`
+		SELECT reservation_id, cart_id, product_id, variant_id, quantity, expires_at
+		FROM stock_reservations WHERE cart_id = $1 ORDER BY reservation_id
+	`, cartId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []modules.StockReservation{}
+	for rows.Next() {
+		var res modules.StockReservation
+		if err := rows.Scan(&res.ReservationId, &res.CartId, &res.ProductId, &res.VariantId, &res.Quantity, &res.ExpiresAt); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+// ReleaseExpiredReservations deletes reservations past their expiry, giving
+// their units back to other carts, and returns how many it deleted.
+func (p *Postgres) ReleaseExpiredReservations() (int, error) {
+	return releaseReservations(p.Db, "reservation expired", "expires_at <= NOW()")
+}
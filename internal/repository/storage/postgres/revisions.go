@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+// revisionFields returns the product fields tracked in revision diffs.
+func revisionFields(product *modules.Product) map[string]any {
+	fields := map[string]any{}
+	if product == nil {
+		return fields
+	}
+
+	copied := *product
+	copied.ProductId, copied.Version, copied.Variants = 0, 0, nil
+
+	data, _ := json.Marshal(copied)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+func diffProducts(before, after *modules.Product) map[string]modules.FieldChange {
+	from, to := revisionFields(before), revisionFields(after)
+	diff := map[string]modules.FieldChange{}
+
+	for k, v := range from {
+		if !reflect.DeepEqual(v, to[k]) {
+			diff[k] = modules.FieldChange{From: v, To: to[k]}
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			diff[k] = modules.FieldChange{From: nil, To: v}
+		}
+	}
+
+	return diff
+}
+
+// recordRevision stores a revision in the same transaction as the change it
+// describes. before is nil for creates and after is nil for deletes.
+func recordRevision(tx *sql.Tx, action string, before, after *modules.Product, actorId int) error {
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO product_revisions (product_id, action, actor_id, diff, snapshot)
+		VALUES ($1, $2, $3, $4, $5)
+	`, snapshot.ProductId, action, nullableId(actorId), jsonb{diffProducts(before, after)}, jsonb{snapshot})
+	if err != nil {
+		return fmt.Errorf("error recording product revision: %w", err)
+	}
+
+	if err := recordPriceChange(tx, before, after, actorId); err != nil {
+		return err
+	}
+	return recordStockChange(tx, action, before, after, actorId)
+}
+
+func (p *Postgres) GetProductHistory(productId int) ([]modules.ProductRevision, error) {
+	rows, err := p.Db.Query(`
+		SELECT revision_id, product_id, action, actor_id, changed_at, diff, snapshot
+		FROM product_revisions
+		WHERE product_id = $1
+		ORDER BY revision_id DESC
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching product history: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []modules.ProductRevision
+	for rows.Next() {
+		var rev modules.ProductRevision
+		err := rows.Scan(&rev.RevisionId, &rev.ProductId, &rev.Action, &rev.ActorId, &rev.ChangedAt, jsonb{&rev.Diff}, jsonb{&rev.Snapshot})
+		if err != nil {
+			return nil, fmt.Errorf("error scanning revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RestoreProductRevision writes a revision's snapshot back to the product,
+// re-creating the row under its old id if it has since been deleted. Stock
+// belongs to the inventory ledger, so the product keeps its current stock,
+// or none when re-created, rather than the snapshot's. A restore that moves
+// the price too far is held for approval.
+func (p *Postgres) RestoreProductRevision(productId int, revisionId int, actorId int) (modules.Product, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.Product{}, err
+	}
+	defer tx.Rollback()
+
+	var snapshot modules.Product
+	err = tx.QueryRow(`SELECT snapshot FROM product_revisions WHERE revision_id = $1 AND product_id = $2`, revisionId, productId).Scan(jsonb{&snapshot})
+	if err == sql.ErrNoRows {
+		return modules.Product{}, fmt.Errorf("%w: revision %d of product %d", storage.ErrNotFound, revisionId, productId)
+	}
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error fetching revision: %w", err)
+	}
+
+	var before *modules.Product
+	current, err := scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.product_id = $1 FOR UPDATE", productId))
+	switch err {
+	case nil:
+		before = &current
+	case sql.ErrNoRows:
+	default:
+		return modules.Product{}, fmt.Errorf("error fetching product: %v", err)
+	}
+	snapshot.Stock = current.Stock
+
+	if before != nil {
+		cr := modules.ChangeRequest{ProductId: productId, BaseVersion: current.Version, Action: "restore", Proposed: snapshot}
+		if err := p.holdPriceChange(tx, current.Price, snapshot.Price, cr, actorId); err != nil {
+			return modules.Product{}, commitHeld(tx, err)
+		}
+	}
+
+	product, err := p.restoreSnapshot(tx, productId, before, snapshot, actorId)
+	if err != nil {
+		return modules.Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.Product{}, err
+	}
+
+	InvalidateProductCache()
+	return product, nil
+}
+
+// restoreSnapshot writes snapshot over before, the locked product, or
+// inserts it under productId when before is nil.
+func (p *Postgres) restoreSnapshot(tx *sql.Tx, productId int, before *modules.Product, snapshot modules.Product, actorId int) (modules.Product, error) {
+	args := append(p.productWriteValues(snapshot), productId)
+	n := len(args)
+
+	var product modules.Product
+	var err error
+	if before != nil {
+		product, err = scanProduct(tx.QueryRow("UPDATE products p SET ("+productWriteColumns+") = ("+placeholders(1, n-1)+"), deleted_at = NULL, version = version + 1 WHERE p.product_id = $"+fmt.Sprint(n)+" RETURNING "+productColumns, args...))
+	} else {
+		product, err = scanProduct(tx.QueryRow("INSERT INTO products AS p ("+productWriteColumns+", product_id) VALUES ("+placeholders(1, n)+") RETURNING "+productColumns, args...))
+	}
+	if err != nil {
+		return modules.Product{}, fmt.Errorf("error restoring product: %v", err)
+	}
+
+	if err := recordRevision(tx, "restore", before, &product, actorId); err != nil {
+		return modules.Product{}, err
+	}
+
+	return product, nil
+}
@@ -1,5 +1,13 @@
 package postgres
 
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
 func (p *Postgres) CreateUser(name string, email string, password string, phone string, role string, address string) (int, error) {
 	stmt, err := p.Db.Prepare("INSERT INTO users (name, email, password,phone, role, address) VALUES ($1, $2, $3, $4,$5, $6) RETURNING user_id")
 
@@ -17,4 +25,19 @@ func (p *Postgres) CreateUser(name string, email string, password string, phone
 	}
 
 	return int(userId), nil
-}
\ No newline at end of file
+}
+
+// GetUserCredentials returns the id and password hash of the user with
+// email.
+func (p *Postgres) GetUserCredentials(email string) (int, string, error) {
+	var userId int
+	var hash string
+	err := p.Db.QueryRow(`SELECT user_id, password FROM users WHERE email = $1`, email).Scan(&userId, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", fmt.Errorf("%w: user with email %q", storage.ErrNotFound, email)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return userId, hash, nil
+}
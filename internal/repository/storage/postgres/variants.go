@@ -0,0 +1,293 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+const variantColumns = `variant_id, product_id, sku, options, price, currency, stock, images`
+
+func scanVariant(row rowScanner) (modules.ProductVariant, error) {
+	var v modules.ProductVariant
+	var amount sql.NullInt64
+	var currency sql.NullString
+	err := row.Scan(&v.VariantId, &v.ProductId, &v.SKU, jsonb{&v.Options}, &amount, &currency, &v.Stock, jsonb{&v.Images})
+	if amount.Valid {
+		v.Price = &modules.Money{Amount: amount.Int64, Currency: currency.String}
+	}
+	return v, err
+}
+
+// variantError reports a duplicate SKU as ErrConflict and an unknown
+// product as ErrNotFound.
+func variantError(err error, productId int, sku string) error {
+	if hasPqCode(err, "23505") {
+		return fmt.Errorf("%w: sku %q is already in use", storage.ErrConflict, sku)
+	}
+	if hasPqCode(err, "23503") {
+		return fmt.Errorf("%w: product %d", storage.ErrNotFound, productId)
+	}
+	return fmt.Errorf("failed to save variant: %w", err)
+}
+
+// variantPrice splits an optional variant price into its columns.
+func (p *Postgres) variantPrice(price *modules.Money) (any, any) {
+	if price == nil {
+		return nil, nil
+	}
+	return price.Amount, p.currencyOf(*price)
+}
+
+// CreateVariant adds a variant, receiving its initial stock into the first
+// warehouse. A variant priced too far from the product's regular price is
+// held for approval.
+func (p *Postgres) CreateVariant(productId int, sku string, options map[string]string, price *modules.Money, stock int, images []modules.ProductImage, actorId int) (int, error) {
+	variant := modules.ProductVariant{ProductId: productId, SKU: sku, Options: options, Price: price, Stock: stock, Images: images}
+
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := p.holdVariantPrice(tx, variant, actorId); err != nil {
+		return 0, commitHeld(tx, err)
+	}
+
+	variantId, err := p.insertVariant(tx, variant, actorId)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	InvalidateProductCache()
+
+	return variantId, nil
+}
+
+// holdVariantPrice holds a variant write for approval when it sets the
+// variant's own price too far from the product's regular price in the same
+// currency. Updates that keep the variant's current price go ahead.
+func (p *Postgres) holdVariantPrice(tx *sql.Tx, variant modules.ProductVariant, actorId int) error {
+	stored, version, err := lockProductPrice(tx, variant.ProductId)
+	if err != nil {
+		return err
+	}
+	if variant.Price == nil {
+		return nil
+	}
+
+	price := *variant.Price
+	price.Currency = p.currencyOf(price)
+
+	if variant.VariantId != 0 {
+		var unchanged bool
+		err := tx.QueryRow(`SELECT price IS NOT DISTINCT FROM $1 AND currency IS NOT DISTINCT FROM $2 FROM product_variants WHERE variant_id = $3`,
+			price.Amount, price.Currency, variant.VariantId).Scan(&unchanged)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error fetching variant: %w", err)
+		}
+		if unchanged {
+			return nil
+		}
+	}
+
+	regular, err := p.regularPriceIn(variant.ProductId, stored, price.Currency)
+	if err != nil {
+		return err
+	}
+
+	cr := modules.ChangeRequest{ProductId: variant.ProductId, BaseVersion: version, Action: "variant", Variant: &variant}
+	return p.holdPriceChange(tx, regular, price, cr, actorId)
+}
+
+func (p *Postgres) insertVariant(tx *sql.Tx, variant modules.ProductVariant, actorId int) (int, error) {
+	amount, currency := p.variantPrice(variant.Price)
+
+	var variantId int
+	err := tx.QueryRow(`
+		INSERT INTO product_variants (product_id, sku, options, price, currency, stock, images)
+		VALUES ($1, $2, $3, $4, $5, 0, $6)
+		RETURNING variant_id
+	`, variant.ProductId, variant.SKU, jsonb{variant.Options}, amount, currency, jsonb{nonNilImages(variant.Images)}).Scan(&variantId)
+	if err != nil {
+		return 0, variantError(err, variant.ProductId, variant.SKU)
+	}
+
+	if _, err := moveStock(tx, variant.ProductId, variantId, 0, variant.Stock, modules.MovementReceipt, "initial stock", actorId); err != nil {
+		return 0, err
+	}
+
+	return variantId, nil
+}
+
+func (p *Postgres) GetVariantsByProductId(productId int) ([]modules.ProductVariant, error) {
+	rows, err := p.Db.Query(`
+		SELECT `+variantColumns+`
+		FROM product_variants
+		WHERE product_id = $1 AND deleted_at IS NULL
+		ORDER BY variant_id
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []modules.ProductVariant
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning variant: %w", err)
+		}
+		variants = append(variants, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return variants, nil
+}
+
+// UpdateVariant replaces a variant, recording any change to its stock as an
+// adjustment spread over warehouses as shiftStock does. A price too far from
+// the product's regular price is held for approval.
+func (p *Postgres) UpdateVariant(productId int, variantId int, sku string, options map[string]string, price *modules.Money, stock int, images []modules.ProductImage, actorId int) (modules.ProductVariant, error) {
+	variant := modules.ProductVariant{VariantId: variantId, ProductId: productId, SKU: sku, Options: options, Price: price, Stock: stock, Images: images}
+
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.ProductVariant{}, err
+	}
+	defer tx.Rollback()
+
+	if err := p.holdVariantPrice(tx, variant, actorId); err != nil {
+		return modules.ProductVariant{}, commitHeld(tx, err)
+	}
+
+	v, err := p.updateVariant(tx, variant, actorId)
+	if err != nil {
+		return modules.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return modules.ProductVariant{}, err
+	}
+
+	InvalidateProductCache()
+
+	return v, nil
+}
+
+func (p *Postgres) updateVariant(tx *sql.Tx, variant modules.ProductVariant, actorId int) (modules.ProductVariant, error) {
+	productId, variantId := variant.ProductId, variant.VariantId
+	amount, currency := p.variantPrice(variant.Price)
+
+	var previous int
+	err := tx.QueryRow(`SELECT stock FROM product_variants WHERE product_id = $1 AND variant_id = $2 AND deleted_at IS NULL FOR UPDATE`, productId, variantId).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return modules.ProductVariant{}, fmt.Errorf("%w: variant %d of product %d", storage.ErrNotFound, variantId, productId)
+	}
+	if err != nil {
+		return modules.ProductVariant{}, fmt.Errorf("error updating variant: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE product_variants
+		SET sku = $1, options = $2, price = $3, currency = $4, images = $5
+		WHERE product_id = $6 AND variant_id = $7`,
+		variant.SKU, jsonb{variant.Options}, amount, currency, jsonb{nonNilImages(variant.Images)}, productId, variantId)
+	if err != nil {
+		return modules.ProductVariant{}, variantError(err, productId, variant.SKU)
+	}
+
+	if _, err := moveStock(tx, productId, variantId, 0, variant.Stock-previous, modules.MovementAdjustment, "variant update", actorId); err != nil {
+		return modules.ProductVariant{}, err
+	}
+
+	v, err := scanVariant(tx.QueryRow(`SELECT `+variantColumns+` FROM product_variants WHERE variant_id = $1`, variantId))
+	if err != nil {
+		return modules.ProductVariant{}, fmt.Errorf("error updating variant: %w", err)
+	}
+
+	return v, nil
+}
+
+// DeleteVariant moves a variant to the trash, like DeleteProductById does for
+// products, and releases the stock carts hold of it. Cart and wishlist lines
+// keep pointing at it, showing as unavailable, until the trash is purged.
+func (p *Postgres) DeleteVariant(productId int, variantId int) error {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE product_variants SET deleted_at = NOW() WHERE product_id = $1 AND variant_id = $2 AND deleted_at IS NULL`, productId, variantId)
+	if err != nil {
+		return fmt.Errorf("error deleting variant: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: variant %d of product %d", storage.ErrNotFound, variantId, productId)
+	}
+
+	if _, err := releaseReservations(tx, "variant deleted", "variant_id = $2", variantId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidateProductCache()
+
+	return nil
+}
+
+// variantPriceAndStock returns the price and stock a cart line should use:
+// the variant's when one is given, the parent product's otherwise.
+func (p *Postgres) variantPriceAndStock(productId int, variantId int, pc modules.PriceContext) (modules.Money, int, error) {
+	var price modules.Money
+	var stock int
+	var ownPrice bool
+
+	if variantId == 0 {
+		err := p.Db.QueryRow(`SELECT p.price, p.currency, p.stock FROM products p WHERE p.product_id = $1 AND `+publicProduct, productId).Scan(&price.Amount, &price.Currency, &stock)
+		if err == sql.ErrNoRows {
+			return modules.Money{}, 0, fmt.Errorf("%w: product %d is not available", storage.ErrNotFound, productId)
+		}
+		if err != nil {
+			return modules.Money{}, 0, fmt.Errorf("failed to fetch product details: %w", err)
+		}
+	} else {
+		err := p.Db.QueryRow(`
+			SELECT COALESCE(v.price, p.price), COALESCE(v.currency, p.currency), v.price IS NOT NULL, v.stock
+			FROM product_variants v
+			JOIN products p ON p.product_id = v.product_id
+			WHERE v.variant_id = $1 AND v.product_id = $2 AND v.deleted_at IS NULL AND `+publicProduct, variantId, productId).Scan(&price.Amount, &price.Currency, &ownPrice, &stock)
+		if err == sql.ErrNoRows {
+			return modules.Money{}, 0, fmt.Errorf("%w: variant %d of product %d", storage.ErrNotFound, variantId, productId)
+		}
+		if err != nil {
+			return modules.Money{}, 0, fmt.Errorf("failed to fetch variant details: %w", err)
+		}
+	}
+
+	resolver, err := p.newPriceResolver(pc, []int{productId})
+	if err != nil {
+		return modules.Money{}, 0, err
+	}
+	price, _, err = resolver.price(productId, price, ownPrice)
+	if err != nil {
+		return modules.Money{}, 0, err
+	}
+
+	return price, stock, nil
+}
@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nkchakradhari780/catalogServices/internal/allocation"
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
+)
+
+const warehouseColumns = `warehouse_id, code, name, latitude, longitude, created_at`
+
+func scanWarehouse(row rowScanner) (modules.Warehouse, error) {
+	var w modules.Warehouse
+	err := row.Scan(&w.WarehouseId, &w.Code, &w.Name, &w.Latitude, &w.Longitude, &w.CreatedAt)
+	return w, err
+}
+
+// warehouseError reports a duplicate code as ErrConflict.
+func warehouseError(err error, w modules.Warehouse) error {
+	if hasPqCode(err, "23505") {
+		return fmt.Errorf("%w: warehouse code %q is already in use", storage.ErrConflict, w.Code)
+	}
+	return fmt.Errorf("failed to save warehouse: %w", err)
+}
+
+func (p *Postgres) CreateWarehouse(w modules.Warehouse) (modules.Warehouse, error) {
+	created, err := scanWarehouse(p.Db.QueryRow(`
+		INSERT INTO warehouses (code, name, latitude, longitude) VALUES ($1, $2, $3, $4)
+		RETURNING `+warehouseColumns, w.Code, w.Name, w.Latitude, w.Longitude))
+	if err != nil {
+		return modules.Warehouse{}, warehouseError(err, w)
+	}
+	return created, nil
+}
+
+func (p *Postgres) UpdateWarehouse(id int, w modules.Warehouse) (modules.Warehouse, error) {
+	updated, err := scanWarehouse(p.Db.QueryRow(`
+		UPDATE warehouses SET code = $1, name = $2, latitude = $3, longitude = $4 WHERE warehouse_id = $5
+		RETURNING `+warehouseColumns, w.Code, w.Name, w.Latitude, w.Longitude, id))
+	if err == sql.ErrNoRows {
+		return modules.Warehouse{}, fmt.Errorf("%w: warehouse %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return modules.Warehouse{}, warehouseError(err, w)
+	}
+	return updated, nil
+}
+
+func (p *Postgres) GetWarehouses() ([]modules.Warehouse, error) {
+	rows, err := p.Db.Query(`SELECT ` + warehouseColumns + ` FROM warehouses ORDER BY warehouse_id`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	warehouses := []modules.Warehouse{}
+	for rows.Next() {
+		w, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	return warehouses, rows.Err()
+}
+
+// GetProductStock returns what each warehouse holds of a product and its
+// variants.
+func (p *Postgres) GetProductStock(productId int) ([]modules.WarehouseStock, error) {
+	rows, err := p.Db.Query(`
+		SELECT s.warehouse_id, w.code, s.product_id, s.variant_id, s.quantity
+		FROM warehouse_stock s JOIN warehouses w ON w.warehouse_id = s.warehouse_id
+		WHERE s.product_id = $1
+		ORDER BY s.variant_id NULLS FIRST, s.warehouse_id
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching warehouse stock: %w", err)
+	}
+	defer rows.Close()
+
+	stock := []modules.WarehouseStock{}
+	for rows.Next() {
+		var s modules.WarehouseStock
+		if err := rows.Scan(&s.WarehouseId, &s.Code, &s.ProductId, &s.VariantId, &s.Quantity); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+
+	return stock, rows.Err()
+}
+
+// lockStockRow locks a product's row, or its variant's, for the rest of tx,
+// bumping the product's version so an edit based on the old stock fails
+// instead of overwriting the change.
+func lockStockRow(tx *sql.Tx, productId int, variantId int) error {
+	var err error
+	if variantId == 0 {
+		err = tx.QueryRow(`UPDATE products SET version = version + 1 WHERE product_id = $1 AND deleted_at IS NULL RETURNING product_id`, productId).Scan(&productId)
+	} else {
+		err = tx.QueryRow(`SELECT variant_id FROM product_variants WHERE product_id = $1 AND variant_id = $2 AND deleted_at IS NULL FOR UPDATE`, productId, variantId).Scan(&variantId)
+	}
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: product %d", storage.ErrNotFound, productId)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock stock: %w", err)
+	}
+	return nil
+}
+
+// stockShift is a change to what one warehouse holds of an item.
+type stockShift struct {
+	warehouseId, quantity int
+}
+
+// shiftStock changes what the warehouses hold of a product or variant (0
+// for none) by delta, then sets the stock on its row to their total, which
+// is what the catalog reads. Additions go to warehouseId, or to the first
+// warehouse when it is 0. Removals come from warehouseId alone, or when it
+// is 0 from the warehouses holding the most, and fail with ErrConflict
+// rather than take any warehouse below zero.
+func shiftStock(tx *sql.Tx, productId int, variantId int, warehouseId int, delta int) ([]stockShift, error) {
+	if delta == 0 {
+		return nil, nil
+	}
+
+	var shifts []stockShift
+	if delta > 0 {
+		if warehouseId == 0 {
+			err := tx.QueryRow(`SELECT warehouse_id FROM warehouses ORDER BY warehouse_id LIMIT 1`).Scan(&warehouseId)
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("%w: there are no warehouses to hold stock", storage.ErrConflict)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch warehouses: %w", err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO warehouse_stock (warehouse_id, product_id, variant_id, quantity) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (warehouse_id, product_id, (COALESCE(variant_id, 0)))
+			DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity
+		`, warehouseId, productId, nullableId(variantId), delta)
+		if hasPqCode(err, "23503") {
+			return nil, fmt.Errorf("%w: warehouse %d", storage.ErrNotFound, warehouseId)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update warehouse stock: %w", err)
+		}
+		shifts = append(shifts, stockShift{warehouseId, delta})
+	} else {
+		rows, err := tx.Query(`
+			SELECT warehouse_id, quantity FROM warehouse_stock
+			WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 AND quantity > 0 AND ($3 = 0 OR warehouse_id = $3)
+			ORDER BY quantity DESC, warehouse_id
+			FOR UPDATE
+		`, productId, nullableId(variantId), warehouseId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch warehouse stock: %w", err)
+		}
+
+		remaining, held := -delta, 0
+		for rows.Next() {
+			var s stockShift
+			if err := rows.Scan(&s.warehouseId, &s.quantity); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			held += s.quantity
+			if remaining > 0 {
+				take := min(remaining, s.quantity)
+				shifts = append(shifts, stockShift{s.warehouseId, -take})
+				remaining -= take
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if remaining > 0 {
+			if warehouseId != 0 {
+				return nil, fmt.Errorf("%w: warehouse %d holds only %d", storage.ErrConflict, warehouseId, held)
+			}
+			return nil, fmt.Errorf("%w: only %d in stock", storage.ErrConflict, held)
+		}
+
+		for _, s := range shifts {
+			_, err := tx.Exec(`
+				UPDATE warehouse_stock SET quantity = quantity + $1
+				WHERE warehouse_id = $2 AND product_id = $3 AND variant_id IS NOT DISTINCT FROM $4
+			`, s.quantity, s.warehouseId, productId, nullableId(variantId))
+			if err != nil {
+				return nil, fmt.Errorf("failed to update warehouse stock: %w", err)
+			}
+		}
+	}
+
+	var err error
+	if variantId == 0 {
+		_, err = tx.Exec(`
+			UPDATE products SET stock = (SELECT COALESCE(SUM(quantity), 0) FROM warehouse_stock WHERE product_id = $1 AND variant_id IS NULL)
+			WHERE product_id = $1
+		`, productId)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE product_variants SET stock = (SELECT COALESCE(SUM(quantity), 0) FROM warehouse_stock WHERE variant_id = $1)
+			WHERE variant_id = $1
+		`, variantId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update stock: %w", err)
+	}
+
+	return shifts, nil
+}
+
+// moveStock shifts stock as shiftStock does and records a movement for
+// each warehouse it touched.
+func moveStock(tx *sql.Tx, productId int, variantId int, warehouseId int, delta int, movementType string, reason string, actorId int) ([]modules.InventoryMovement, error) {
+	shifts, err := shiftStock(tx, productId, variantId, warehouseId, delta)
+	if err != nil {
+		return nil, err
+	}
+	return recordShifts(tx, productId, variantId, shifts, movementType, reason, actorId)
+}
+
+func recordShifts(tx *sql.Tx, productId int, variantId int, shifts []stockShift, movementType string, reason string, actorId int) ([]modules.InventoryMovement, error) {
+	movements := []modules.InventoryMovement{}
+	for _, s := range shifts {
+		m, err := scanMovement(tx.QueryRow(`
+			INSERT INTO inventory_movements (product_id, variant_id, warehouse_id, type, quantity, reason, actor_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+movementColumns,
+			productId, nullableId(variantId), s.warehouseId, movementType, s.quantity, reason, nullableId(actorId)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+// TransferStock moves stock of a product or variant between warehouses,
+// recording the move out and the move in. The total is unchanged, so
+// reservations and the catalog are unaffected.
+func (p *Postgres) TransferStock(transfer modules.StockTransfer, actorId int) ([]modules.InventoryMovement, error) {
+	variantId := 0
+	if transfer.VariantId != nil {
+		variantId = *transfer.VariantId
+	}
+	reason := transfer.Reason
+	if reason == "" {
+		reason = "warehouse transfer"
+	}
+
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockStockRow(tx, transfer.ProductId, variantId); err != nil {
+		return nil, err
+	}
+
+	out, err := shiftStock(tx, transfer.ProductId, variantId, transfer.FromWarehouseId, -transfer.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	in, err := shiftStock(tx, transfer.ProductId, variantId, transfer.ToWarehouseId, transfer.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	movements, err := recordShifts(tx, transfer.ProductId, variantId, append(out, in...), modules.MovementTransfer, reason, actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// lockStockRow bumped the version, so the cached copy's ETag is stale.
+	InvalidateProductCacheById(transfer.ProductId)
+	return movements, nil
+}
+
+// AllocateCart plans which warehouses ship the owner's active cart, using
+// strategy and, for AllocateNearest, the customer's location.
+func (p *Postgres) AllocateCart(owner modules.CartOwner, strategy string, origin *modules.Location) (modules.CartAllocation, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return modules.CartAllocation{}, err
+	}
+	defer tx.Rollback()
+
+	cartId, err := activeCartId(tx, owner)
+	if err != nil {
+		return modules.CartAllocation{}, err
+	}
+
+	return allocateCart(tx, cartId, strategy, origin)
+}
+
+// allocateCart plans which warehouses ship a cart from what they hold now.
+func allocateCart(tx *sql.Tx, cartId int, strategy string, origin *modules.Location) (modules.CartAllocation, error) {
+	rows, err := tx.Query(`
+		SELECT ci.cart_item_id, ci.quantity, s.warehouse_id, w.latitude, w.longitude, s.quantity
+		FROM cartItems ci
+		LEFT JOIN warehouse_stock s ON s.product_id = ci.product_id AND s.variant_id IS NOT DISTINCT FROM ci.variant_id AND s.quantity > 0
+		LEFT JOIN warehouses w ON w.warehouse_id = s.warehouse_id
+		WHERE ci.cart_id = $1
+		ORDER BY ci.cart_item_id
+	`, cartId)
+	if err != nil {
+		return modules.CartAllocation{}, fmt.Errorf("error fetching cart stock: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []allocation.Line
+	for rows.Next() {
+		var id, quantity int
+		var warehouseId, held sql.NullInt64
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&id, &quantity, &warehouseId, &latitude, &longitude, &held); err != nil {
+			return modules.CartAllocation{}, err
+		}
+
+		if len(lines) == 0 || lines[len(lines)-1].Id != id {
+			lines = append(lines, allocation.Line{Id: id, Quantity: quantity})
+		}
+		if !warehouseId.Valid {
+			continue
+		}
+
+		source := allocation.Source{WarehouseId: int(warehouseId.Int64), Quantity: int(held.Int64)}
+		if latitude.Valid && longitude.Valid {
+			source.Location = &modules.Location{Latitude: latitude.Float64, Longitude: longitude.Float64}
+		}
+		line := &lines[len(lines)-1]
+		line.Sources = append(line.Sources, source)
+	}
+	if err := rows.Err(); err != nil {
+		return modules.CartAllocation{}, err
+	}
+
+	return allocation.Allocate(lines, strategy, origin), nil
+}
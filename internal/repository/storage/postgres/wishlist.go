@@ -4,12 +4,16 @@ import (
 	"database/sql"
 	"fmt"
 
-	"github.com/lib/pq"
 	"github.com/nkchakradhari780/catalogServices/internal/modules"
+	"github.com/nkchakradhari780/catalogServices/internal/repository/storage"
 )
 
-func (p *Postgres) AddToWishList(user_id int, product_id int) (int, error) {
-	stmt, err := p.Db.Prepare("INSERT INTO wishList (product_id, user_id) VALUES ($1, $2) ON CONFLICT (user_id, product_id) DO NOTHING RETURNING wish_list_id")
+func (p *Postgres) AddToWishList(user_id int, product_id int, variant_id int) (int, error) {
+	if _, _, err := p.variantPriceAndStock(product_id, variant_id, modules.PriceContext{}); err != nil {
+		return 0, err
+	}
+
+	stmt, err := p.Db.Prepare("INSERT INTO wishList (product_id, user_id, variant_id) VALUES ($1, $2, $3) ON CONFLICT (user_id, product_id, (COALESCE(variant_id, 0))) DO NOTHING RETURNING wish_list_id")
 	if err != nil {
 		return 0, err
 	}
@@ -17,7 +21,7 @@ func (p *Postgres) AddToWishList(user_id int, product_id int) (int, error) {
 
 	var wishListId int
 
-	err = stmt.QueryRow(product_id, user_id).Scan(&wishListId)
+	err = stmt.QueryRow(product_id, user_id, nullableId(variant_id)).Scan(&wishListId)
 
 	if err == sql.ErrNoRows {
 		return 0, fmt.Errorf("product already added to wish list")
@@ -30,25 +34,27 @@ func (p *Postgres) AddToWishList(user_id int, product_id int) (int, error) {
 	return int(wishListId), nil
 }
 
-func (p *Postgres) RemoveFromWishList(user_id int, product_id int) error {
+// RemoveFromWishList removes a product, or only its variant when variant_id
+// is set, from the user's wishlist.
+func (p *Postgres) RemoveFromWishList(user_id int, product_id int, variant_id int) error {
 
 	var wishListId int
 
-	err := p.Db.QueryRow(`SELECT wish_list_id FROM wishList WHERE user_id = $1 AND product_id = $2`, user_id, product_id).Scan(&wishListId)
+	err := p.Db.QueryRow(`SELECT wish_list_id FROM wishList WHERE user_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3`, user_id, product_id, nullableId(variant_id)).Scan(&wishListId)
 
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("item not found on wishlist")
+		return fmt.Errorf("%w: item not found on wishlist", storage.ErrNotFound)
 	} else if err != nil {
 		return fmt.Errorf("error fetching item from wishlist: %w", err)
 	}
 
-	stmt, err := p.Db.Prepare(`DELETE FROM wishList WHERE user_id = $1 AND product_id = $2`)
+	stmt, err := p.Db.Prepare(`DELETE FROM wishList WHERE wish_list_id = $1`)
 
 	if err != nil {
 		return fmt.Errorf("error removing product from wishlist")
 	}
 
-	_, err = stmt.Exec(user_id, product_id)
+	_, err = stmt.Exec(wishListId)
 
 	if err != nil {
 		return fmt.Errorf("error removing product from wishlist")
@@ -57,13 +63,13 @@ func (p *Postgres) RemoveFromWishList(user_id int, product_id int) error {
 	return nil
 }
 
-func (p *Postgres) FetchWishListItems(user_id int) ([]modules.WishList, []modules.Product, error) {
+func (p *Postgres) FetchWishListItems(user_id int, pc modules.PriceContext) ([]modules.WishList, []modules.Product, error) {
 
-	rows, err := p.Db.Query(`SELECT wi.wish_list_id, wi.product_id, wi.user_id, wi.added_at,
-					p.product_id, p.name, p.price, p.stock, p.category_id, p.quantity, p.brand, p.images
+	rows, err := p.Db.Query(`SELECT `+productColumns+`,
+					wi.wish_list_id, wi.product_id, wi.user_id, wi.variant_id, wi.added_at
 				FROM wishList wi
 				JOIN products p ON wi.product_id = p.product_id
-				WHERE wi.user_id = $1
+				WHERE wi.user_id = $1 AND p.deleted_at IS NULL
 	`, user_id)
 
 	if err != nil {
@@ -77,9 +83,8 @@ func (p *Postgres) FetchWishListItems(user_id int) ([]modules.WishList, []module
 
 	for rows.Next() {
 		var wi modules.WishList
-		var p modules.Product
 
-		err := rows.Scan(&wi.WishListId, &wi.ProductId, &wi.UserId, &wi.AddedAt, &p.ProductId, &p.Name, &p.Price, &p.Quantity, &p.Stock, &p.Brand, &p.CategoryID, pq.Array(&p.Images))
+		p, err := scanProduct(rows, &wi.WishListId, &wi.ProductId, &wi.UserId, &wi.VariantId, &wi.AddedAt)
 
 		
 		if err != nil {
@@ -94,5 +99,9 @@ func (p *Postgres) FetchWishListItems(user_id int) ([]modules.WishList, []module
 		return nil,nil, fmt.Errorf("row iteration err: %w", err)
 	}
 
+	if _, err := p.priceProducts(products, pc); err != nil {
+		return nil, nil, err
+	}
+
 	return wishlistItems, products, nil
 }
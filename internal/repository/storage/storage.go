@@ -1,25 +1,108 @@
 package storage
 
-import "github.com/nkchakradhari780/catalogServices/internal/modules"
+import (
+	"time"
+
+	"github.com/nkchakradhari780/catalogServices/internal/modules"
+)
 
 type Storage interface {
-	CreateProduct(name string, price int, stock int, categoryId string, quantity int, Brand string, Images []string) (int, error)
-	GetProductById(id int) (modules.Product, error)
-	GetProducts() ([]modules.Product, error)
-	GetDefaultProducts() ([]modules.Product, error)
-	GetFilteredProducts(filters map[string][]string) ([]modules.Product, error)
-	UpdateProductById(id int, name string, price int, stock int, categoryId string, quantity int, brand string, images []string) (modules.Product, error)
-	DeleteProductById(id int) error
-	SearchProducts(qureyStr string) ([]modules.Product, error)
+	CreateProduct(product modules.Product, actorId int) (int, error)
+	GetProductById(id int, pc modules.PriceContext) (modules.Product, error)
+	GetProducts(pc modules.PriceContext) ([]modules.Product, error)
+	GetDefaultProducts(pc modules.PriceContext) ([]modules.Product, error)
+	GetFilteredProducts(filters map[string][]string, pc modules.PriceContext) ([]modules.Product, error)
+	UpdateProductById(id int, version int, product modules.Product, actorId int) (modules.Product, error)
+	GetAdminProductById(id int) (modules.Product, error)
+	PatchProductById(id int, version int, changes map[string]any, actorId int) (modules.Product, error)
+	DeleteProductById(id int, version int, actorId int) error
+	ImportProducts(batch []modules.ImportRow, dryRun bool, actorId int) ([]modules.ImportResult, error)
+	GetDeletedProducts() ([]modules.Product, error)
+	RestoreDeletedProduct(id int, actorId int) (modules.Product, error)
+	PurgeDeletedProducts(retention time.Duration) (int, error)
+	PublishScheduledProducts() ([]int, error)
+	GetProductHistory(productId int) ([]modules.ProductRevision, error)
+	RestoreProductRevision(productId int, revisionId int, actorId int) (modules.Product, error)
+	SearchProducts(qureyStr string, pc modules.PriceContext) ([]modules.Product, error)
+	GetAdminProducts(filters map[string][]string) ([]modules.Product, error)
+	StreamProducts(filters map[string][]string, fn func(modules.Product) error) error
+	SetProductPrice(id int, amount int64, actorId int) (bool, error)
+	ReindexProducts() error
+
+	GetProductPrices(productId int) ([]modules.Money, error)
+	GetPriceHistory(productId int, currency string) ([]modules.PriceChange, error)
+	SetProductPriceIn(productId int, price modules.Money, actorId int) error
+	DeleteProductPriceIn(productId int, currency string) error
+	GetExchangeRates() ([]modules.ExchangeRate, error)
+	SetExchangeRate(from string, to string, rate float64) (modules.ExchangeRate, error)
+	DeleteExchangeRate(from string, to string) error
+
+	CreateUserGroup(name string) (modules.UserGroup, error)
+	GetUserGroups() ([]modules.UserGroup, error)
+	AddUserToGroup(groupId int, userId int) error
+	RemoveUserFromGroup(groupId int, userId int) error
+	CreatePriceList(list modules.PriceList, actorId int) (modules.PriceList, error)
+	UpdatePriceList(id int, list modules.PriceList, actorId int) (modules.PriceList, error)
+	GetPriceLists() ([]modules.PriceList, error)
+	DeletePriceList(id int) error
+	CreateSalePrice(productId int, sale modules.SalePrice, actorId int) (modules.SalePrice, error)
+	GetSalePrices(productId int) ([]modules.SalePrice, error)
+	DeleteSalePrice(productId int, saleId int) error
+
+	GetChangeRequests(status string) ([]modules.ChangeRequest, error)
+	ApproveChangeRequest(id int, approverId int, note string) (modules.ChangeRequest, error)
+	RejectChangeRequest(id int, reviewerId int, note string) (modules.ChangeRequest, error)
+
+	CreateJob(jobType string, params any, createdBy int) (modules.Job, error)
+	GetJob(id int) (modules.Job, error)
+	ClaimJob() (modules.Job, error)
+	UpdateJobProgress(id int, progress int, total int) (bool, error)
+	FinishJob(id int, status string, result any, errMsg string) error
+	RequeueJob(id int) error
+	RequeueRunningJobs() (int, error)
+	CancelJob(id int) (modules.Job, error)
+
+	SetAttributeSchema(categoryId int, schema modules.AttributeSchema) error
+	GetAttributeSchema(categoryId int) (modules.AttributeSchema, error)
+
+	CreateVariant(productId int, sku string, options map[string]string, price *modules.Money, stock int, images []modules.ProductImage, actorId int) (int, error)
+	GetVariantsByProductId(productId int) ([]modules.ProductVariant, error)
+	UpdateVariant(productId int, variantId int, sku string, options map[string]string, price *modules.Money, stock int, images []modules.ProductImage, actorId int) (modules.ProductVariant, error)
+	DeleteVariant(productId int, variantId int) error
 
 	CreateUser(name string, email string, password string, phone string, role string, address string) (int, error)
+	GetUserCredentials(email string) (int, string, error)
+
+	AddToWishList(user_id int, product_id int, variant_id int) (int, error)
+	RemoveFromWishList(user_id int, product_id int, variant_id int) error 
+	FetchWishListItems(user_id int, pc modules.PriceContext) ([]modules.WishList, []modules.Product, error)
+
+	AddToCart(owner modules.CartOwner, product_id int, variant_id int, quantity int, pc modules.PriceContext) (int, error)
+	RemoveFromCart(owner modules.CartOwner, product_id int, variant_id int) error 
+	UpdateCartItemQuantity(owner modules.CartOwner, product_id int, variant_id int, quantity int) (modules.CartTotals, error)
+	ClearCart(owner modules.CartOwner) error
+	GetCart(owner modules.CartOwner, pc modules.PriceContext) (modules.CartSummary, error)
+	MergeGuestCart(guestId string, userId int, strategy string) error
+	PurgeGuestCarts(ttl time.Duration) (int, error)
+	ReserveCart(owner modules.CartOwner) ([]modules.StockReservation, error)
+	PlaceOrder(owner modules.CartOwner, strategy string, origin *modules.Location) (modules.Order, error)
+	ReleaseExpiredReservations() (int, error)
 
-	AddToWishList(user_id int, product_id int) (int, error)
-	RemoveFromWishList(user_id int, product_id int) error 
-	FetchWishListItems(user_id int) ([]modules.WishList, []modules.Product, error)
+	PostInventoryMovement(productId int, movement modules.InventoryMovement, actorId int) ([]modules.InventoryMovement, error)
+	GetInventoryMovements(productId int) ([]modules.InventoryMovement, error)
+	ReconcileInventory(fix bool) ([]modules.StockDiscrepancy, error)
+	CreateWarehouse(warehouse modules.Warehouse) (modules.Warehouse, error)
+	UpdateWarehouse(id int, warehouse modules.Warehouse) (modules.Warehouse, error)
+	GetWarehouses() ([]modules.Warehouse, error)
+	GetProductStock(productId int) ([]modules.WarehouseStock, error)
+	TransferStock(transfer modules.StockTransfer, actorId int) ([]modules.InventoryMovement, error)
+	AllocateCart(owner modules.CartOwner, strategy string, origin *modules.Location) (modules.CartAllocation, error)
+	ApplyCoupon(owner modules.CartOwner, code string) (modules.CartTotals, error)
+	RemoveCoupon(owner modules.CartOwner) (modules.CartTotals, error)
 
-	AddToCart(user_id int, product_id int, quantity int, discount int) (int, error)
-	RemoveFromCart(user_id int, product_id int) error 
-	FetchCartItems(user_id int) ([]modules.CartItem, []modules.Product, error)
+	CreatePromotion(promo modules.Promotion) (modules.Promotion, error)
+	UpdatePromotion(id int, promo modules.Promotion) (modules.Promotion, error)
+	GetPromotions() ([]modules.Promotion, error)
+	DeletePromotion(id int) error
 }
 
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Every runs task once per interval until ctx is cancelled. Errors are logged
+// and the next tick runs as usual.
+func Every(ctx context.Context, interval time.Duration, name string, task func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Background task started", slog.String("task", name), slog.String("interval", interval.String()))
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Background task stopped", slog.String("task", name))
+			return
+		case <-ticker.C:
+			if err := task(ctx); err != nil {
+				slog.Error("Background task failed", slog.String("task", name), slog.String("error", err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package mergepatch
+
+// Apply applies an RFC 7396 JSON Merge Patch to target. Both values are
+// expected in the shape produced by encoding/json decoding into any.
+func Apply(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	result := make(map[string]any, len(targetObj))
+	for k, v := range targetObj {
+		result[k] = v
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = Apply(result[k], v)
+	}
+
+	return result
+}